@@ -1,5 +1,7 @@
 import { Iodine } from '@kingshott/iodine'
 import websocket from './websocket'
+import sse from './sse'
+import poll from './poll'
 import morph from '@alpinejs/morph'
 
 const Plugin = (Alpine) => {
@@ -16,29 +18,78 @@ const Plugin = (Alpine) => {
         Alpine.store(storeName, nextStore)
     }
 
-    const getSessionIDFromCookie = () => {
-        return document.cookie
-            .split('; ')
-            .find((row) => row.startsWith('_fir_session_='))
-            ?.split('=')[1]
+    // the page session id is rendered by the server for each page load. it identifies the route and the page
+    // which sent an event, so that tabs on different routes can share a browser session.
+    const getSessionID = () => {
+        const meta = document.querySelector('meta[name="fir-session-id"]')
+        if (meta && meta.content) {
+            return meta.content
+        }
+        return undefined
+    }
+
+    const withSessionID = (path) => {
+        return `${path}?session_id=${encodeURIComponent(getSessionID())}`
     }
 
     // connect to websocket
-    let connectURL = `ws://${window.location.host}${window.location.pathname}`
+    let connectURL = `ws://${window.location.host}${withSessionID(
+        window.location.pathname
+    )}`
     if (window.location.protocol === 'https:') {
-        connectURL = `wss://${window.location.host}${window.location.pathname}`
+        connectURL = `wss://${window.location.host}${withSessionID(
+            window.location.pathname
+        )}`
     }
 
+    const getTransport = () => {
+        const meta = document.querySelector('meta[name="fir-transport"]')
+        if (meta && meta.content) {
+            return meta.content
+        }
+        return 'websocket'
+    }
+
+    // connect to the server event stream. falls back to the next transport if a transport is unavailable:
+    // websocket -> sse -> longpoll
     let socket
-    if (getSessionIDFromCookie()) {
+    const connectPoll = () => {
+        socket = poll(withSessionID(window.location.pathname), (events) =>
+            dispatchServerEvents(events)
+        )
+    }
+    const connectSSE = () => {
+        socket = sse(
+            withSessionID(window.location.pathname),
+            (events) => dispatchServerEvents(events),
+            () => {
+                console.warn('sse is unavailable. falling back to long polling')
+                connectPoll()
+            }
+        )
+    }
+    const connectWebsocket = () => {
         socket = websocket(
             connectURL,
             [],
             (events) => dispatchServerEvents(events),
-            updateStore
+            () => {
+                console.warn('websocket is unavailable. falling back to sse')
+                connectSSE()
+            }
         )
+    }
+
+    if (getSessionID()) {
+        if (getTransport() === 'longpoll') {
+            connectPoll()
+        } else if (getTransport() === 'sse') {
+            connectSSE()
+        } else {
+            connectWebsocket()
+        }
     } else {
-        console.error('no route id found in cookie. websocket disabled')
+        console.error('no route id found in cookie. server events disabled')
     }
 
     window.addEventListener('fir:reload', () => {
@@ -183,7 +234,7 @@ const Plugin = (Alpine) => {
                         params: params,
                         target: target,
                         element_key: el.getAttribute('key'),
-                        session_id: getSessionIDFromCookie(),
+                        session_id: getSessionID(),
                     })
                 }
             },
@@ -268,9 +319,17 @@ const Plugin = (Alpine) => {
                             )
                         }
                         let params = {}
-                        formData.forEach(
-                            (value, key) => (params[key] = new Array(value))
-                        )
+                        // files are uploaded as multipart form data
+                        const files = new FormData()
+                        formData.forEach((value, key) => {
+                            if (value instanceof File) {
+                                if (value.name) {
+                                    files.append(key, value)
+                                }
+                                return
+                            }
+                            params[key] = new Array(value)
+                        })
                         let target = ''
 
                         if (opts) {
@@ -302,14 +361,18 @@ const Plugin = (Alpine) => {
                         }
 
                         // post event to server
-                        post(el, {
-                            event_id: eventID,
-                            params: params,
-                            is_form: true,
-                            target: target,
-                            element_key: el.getAttribute('key'),
-                            session_id: getSessionIDFromCookie(),
-                        })
+                        post(
+                            el,
+                            {
+                                event_id: eventID,
+                                params: params,
+                                is_form: true,
+                                target: target,
+                                element_key: el.getAttribute('key'),
+                                session_id: getSessionID(),
+                            },
+                            files
+                        )
 
                         if (formMethod.toLowerCase() === 'get') {
                             const url = new URL(window.location)
@@ -418,6 +481,15 @@ const Plugin = (Alpine) => {
             if (eventName === 'onevent' || eventName === 'onload') {
                 return
             }
+            // flash and navigation events are not the result of an event
+            if (
+                eventName === 'flash' ||
+                eventName === 'redirect' ||
+                eventName === 'push-url' ||
+                eventName === 'replace-url'
+            ) {
+                return
+            }
             if (doneEvents.has(eventName)) {
                 return
             }
@@ -437,6 +509,29 @@ const Plugin = (Alpine) => {
         })
     }
 
+    // navigate handles the server driven navigation events. returns true if the event was a navigation event.
+    const navigate = (serverEvent) => {
+        const url = serverEvent.detail && serverEvent.detail.url
+        switch (serverEvent.type) {
+            case 'fir:redirect':
+                if (url) {
+                    window.location.assign(url)
+                }
+                return true
+            case 'fir:push-url':
+                if (url) {
+                    window.history.pushState({}, '', url)
+                }
+                return true
+            case 'fir:replace-url':
+                if (url) {
+                    window.history.replaceState({}, '', url)
+                }
+                return true
+        }
+        return false
+    }
+
     const dispatchServerEvent = (serverEvent) => {
         const opts = {
             detail: serverEvent.detail,
@@ -447,6 +542,9 @@ const Plugin = (Alpine) => {
         }
         const renderEvent = new CustomEvent(serverEvent.type, opts)
         window.dispatchEvent(renderEvent)
+        if (navigate(serverEvent)) {
+            return
+        }
         if (serverEvent.target && serverEvent.target.startsWith('#')) {
             const elem = document.getElementById(
                 serverEvent.target.substring(1)
@@ -500,7 +598,7 @@ const Plugin = (Alpine) => {
         }
     }
 
-    const post = (el, firEvent) => {
+    const post = (el, firEvent, files) => {
         if (!firEvent.event_id) {
             throw new Error('event id is required.')
         }
@@ -534,15 +632,22 @@ const Plugin = (Alpine) => {
             )
         }
 
-        if (socket && socket.emit(firEvent)) {
+        const hasFiles = files && !files.keys().next().done
+        if (!hasFiles && socket && socket.emit(firEvent)) {
         } else {
-            const body = JSON.stringify(firEvent)
+            let body = JSON.stringify(firEvent)
+            const headers = { 'X-FIR-MODE': 'event' }
+            if (hasFiles) {
+                // the event is sent in the _fir_event field along with the files.
+                // the browser sets the multipart content type and boundary.
+                files.append('_fir_event', body)
+                body = files
+            } else {
+                headers['Content-Type'] = 'application/json'
+            }
             fetch(window.location.pathname, {
                 method: 'POST',
-                headers: {
-                    'Content-Type': 'application/json',
-                    'X-FIR-MODE': 'event',
-                },
+                headers: headers,
                 body: body,
             })
                 .then((response) => response.json())
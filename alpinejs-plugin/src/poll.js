@@ -0,0 +1,56 @@
+const retryTimeouts = [1000, 2000, 5000, 10000, 30000]
+
+export default poll = (url, dispatchServerEvents) => {
+    let pollID
+    let retryCount = 0
+
+    function retryTimeout() {
+        const n = retryCount
+        retryCount++
+        return retryTimeouts[
+            n >= retryTimeouts.length - 1 ? retryTimeouts.length - 1 : n
+        ]
+    }
+
+    function next() {
+        const headers = { 'X-FIR-MODE': 'poll' }
+        if (pollID) {
+            headers['X-FIR-POLL-ID'] = pollID
+        }
+        fetch(url, { method: 'GET', headers: headers })
+            .then((response) => {
+                if (response.status === 404) {
+                    // the polling session expired. start a new one
+                    pollID = undefined
+                    return []
+                }
+                if (!response.ok) {
+                    throw new Error(`poll error: ${response.status}`)
+                }
+                if (response.headers.get('X-FIR-POLL-ID')) {
+                    pollID = response.headers.get('X-FIR-POLL-ID')
+                }
+                return response.json()
+            })
+            .then((batches) => {
+                retryCount = 0
+                batches.forEach((serverEvents) =>
+                    dispatchServerEvents(serverEvents)
+                )
+                next()
+            })
+            .catch((e) => {
+                console.error(e)
+                setTimeout(next, retryTimeout())
+            })
+    }
+
+    next()
+
+    return {
+        // client events are posted to the server with X-FIR-MODE: event
+        emit(value) {
+            return false
+        },
+    }
+}
@@ -0,0 +1,29 @@
+export default sse = (url, dispatchServerEvents, onUnavailable) => {
+    let opened = false
+    const source = new EventSource(url)
+
+    source.onopen = () => {
+        opened = true
+    }
+    source.onmessage = (event) => {
+        try {
+            const serverEvents = JSON.parse(event.data)
+            dispatchServerEvents(serverEvents)
+        } catch (e) {}
+    }
+    source.onerror = () => {
+        // EventSource reconnects by itself once it has connected.
+        // If it never connected, the event stream is not reachable.
+        if (!opened && onUnavailable) {
+            source.close()
+            onUnavailable()
+        }
+    }
+
+    return {
+        // client events are posted to the server with X-FIR-MODE: event
+        emit(value) {
+            return false
+        },
+    }
+}
@@ -1,8 +1,17 @@
 const reopenTimeouts = [500, 1000, 1500, 2000, 5000, 10000, 30000, 60000]
 
-export default websocket = (url, socketOptions, dispatchServerEvents) => {
+export default websocket = (
+    url,
+    socketOptions,
+    dispatchServerEvents,
+    onUnavailable
+) => {
     let socket, openPromise, reopenTimeoutHandler
     let reopenCount = 0
+    // opened is true once a connection was established.
+    // unavailable is true if the first connection failed and the socket was handed over to onUnavailable.
+    let opened = false
+    let unavailable = false
 
     // socket code copied from https://github.com/arlac77/svelte-websocket-store/blob/master/src/index.mjs
     // thank you https://github.com/arlac77 !!
@@ -26,6 +35,9 @@ export default websocket = (url, socketOptions, dispatchServerEvents) => {
     }
 
     function reOpenSocket() {
+        if (unavailable) {
+            return
+        }
         closeSocket()
         reopenTimeoutHandler = setTimeout(() => {
             openSocket()
@@ -68,8 +80,14 @@ export default websocket = (url, socketOptions, dispatchServerEvents) => {
             socket.onerror = (error) => {
                 reject(error)
                 openPromise = undefined
+                if (!opened && onUnavailable) {
+                    unavailable = true
+                    closeSocket()
+                    onUnavailable()
+                }
             }
             socket.onopen = (event) => {
+                opened = true
                 reopenCount = 0
                 resolve()
                 openPromise = undefined
@@ -84,6 +102,9 @@ export default websocket = (url, socketOptions, dispatchServerEvents) => {
 
     return {
         emit(value) {
+            if (unavailable) {
+                return false
+            }
             const send = () => {
                 if (socket && socket.readyState === WebSocket.OPEN) {
                     socket.send(JSON.stringify(value))
@@ -0,0 +1,147 @@
+package fir
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/schema"
+)
+
+// Binder decodes the params of a request into a struct. RouteContext.Bind calls it for the path params, the query params
+// and the event params, in that order, so a value from a later source overrides the value of an earlier one:
+//
+//	path params < query params < event params(form values or json)
+type Binder interface {
+	// BindPathParams decodes the route's path params, e.g. {id} in /todos/{id}
+	BindPathParams(v any, params PathParams) error
+	// BindQueryParams decodes the url query params
+	BindQueryParams(v any, values url.Values) error
+	// BindForm decodes the values of a submitted form
+	BindForm(v any, values url.Values) error
+	// BindJSON decodes the json params of an event
+	BindJSON(v any, data []byte) error
+}
+
+// WithBinder is an option to set the binder used by RouteContext.Bind. The default binder decodes
+// all the sources with the controller's form decoder. See NewSchemaBinder.
+func WithBinder(binder Binder) ControllerOption {
+	return func(o *opt) {
+		o.binder = binder
+	}
+}
+
+// NewFormDecoder returns the controller's default form decoder: fields are matched by their json name, unknown keys are ignored
+// and time.Time and uuid.UUID values are converted. time.Time accepts RFC3339, datetime-local(2006-01-02T15:04) and date(2006-01-02) values.
+// Register converters for other types with RegisterConverter and set the decoder with WithFormDecoder.
+func NewFormDecoder() *schema.Decoder {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+	decoder.SetAliasTag("json")
+	RegisterConverter(decoder, parseTime)
+	RegisterConverter(decoder, uuid.Parse)
+	return decoder
+}
+
+// RegisterConverter registers a function to convert a string value into a T field, e.g. an enum type.
+// A conversion error is reported as a field error by RouteContext.BindAndValidate.
+func RegisterConverter[T any](decoder *schema.Decoder, convert func(value string) (T, error)) {
+	var zero T
+	decoder.RegisterConverter(zero, func(value string) reflect.Value {
+		v, err := convert(value)
+		if err != nil {
+			return reflect.Value{}
+		}
+		return reflect.ValueOf(v)
+	})
+}
+
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+func parseTime(value string) (time.Time, error) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// NewSchemaBinder returns a Binder which decodes the path params, query params and form values with the
+// gorilla/schema decoder, so that a string value is converted to the type of its field the same way for all sources.
+// JSON params are decoded with encoding/json. If a flat JSON object has a value of the wrong type, e.g. "1" for an int field,
+// it is decoded with the schema decoder too.
+func NewSchemaBinder(decoder *schema.Decoder) Binder {
+	return &schemaBinder{decoder: decoder}
+}
+
+type schemaBinder struct {
+	decoder *schema.Decoder
+}
+
+func (b *schemaBinder) BindPathParams(v any, params PathParams) error {
+	if len(params) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for k, param := range params {
+		values.Set(k, fmt.Sprint(param))
+	}
+	return b.decoder.Decode(v, values)
+}
+
+func (b *schemaBinder) BindQueryParams(v any, values url.Values) error {
+	return b.decoder.Decode(v, values)
+}
+
+func (b *schemaBinder) BindForm(v any, values url.Values) error {
+	return b.decoder.Decode(v, values)
+}
+
+func (b *schemaBinder) BindJSON(v any, data []byte) error {
+	err := json.Unmarshal(data, v)
+	var typeError *json.UnmarshalTypeError
+	if err == nil || !errors.As(err, &typeError) {
+		return err
+	}
+	values, ok := flatJSONValues(data)
+	if !ok {
+		return err
+	}
+	return b.decoder.Decode(v, values)
+}
+
+// flatJSONValues returns the values of a json object whose values are scalars or arrays of scalars
+func flatJSONValues(data []byte) (url.Values, bool) {
+	var m map[string]any
+	// numbers are kept as written since fmt.Sprint of a float64 gives exponents e.g. 1e+06 for 1000000
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, false
+	}
+	values := url.Values{}
+	for k, v := range m {
+		switch val := v.(type) {
+		case nil:
+		case map[string]any:
+			return nil, false
+		case []any:
+			for _, item := range val {
+				switch item.(type) {
+				case map[string]any, []any:
+					return nil, false
+				}
+				values.Add(k, fmt.Sprint(item))
+			}
+		default:
+			values.Set(k, fmt.Sprint(val))
+		}
+	}
+	return values, true
+}
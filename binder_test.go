@@ -0,0 +1,90 @@
+package fir
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	firErrors "github.com/livefir/fir/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type todoStatus string
+
+func parseTodoStatus(value string) (todoStatus, error) {
+	switch value {
+	case "open", "done":
+		return todoStatus(value), nil
+	}
+	return "", fmt.Errorf("invalid status %q", value)
+}
+
+type todoReq struct {
+	ID     uuid.UUID  `json:"id"`
+	Page   int        `json:"page"`
+	Due    time.Time  `json:"due"`
+	Status todoStatus `json:"status"`
+	Title  string     `json:"title"`
+}
+
+func newBindContext(target string, pathParams PathParams, event Event) RouteContext {
+	decoder := NewFormDecoder()
+	RegisterConverter(decoder, parseTodoStatus)
+	c := NewController("test", WithPublicDir("."), WithFormDecoder(decoder)).(*controller)
+	r := httptest.NewRequest("GET", target, nil)
+	r = r.WithContext(context.WithValue(r.Context(), PathParamsKey, pathParams))
+	return RouteContext{event: event, request: r, route: &route{cntrl: c, routeOpt: routeOpt{opt: c.opt}}}
+}
+
+func TestBindCoercesAllSources(t *testing.T) {
+	id := uuid.New()
+	ctx := newBindContext("/?page=2&due=2023-04-05&title=query", PathParams{"id": id.String()},
+		Event{Params: json.RawMessage(`{"title":"event","status":"done"}`)})
+	var req todoReq
+	assert.NoError(t, ctx.Bind(&req))
+	assert.Equal(t, id, req.ID)
+	assert.Equal(t, 2, req.Page)
+	assert.Equal(t, time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC), req.Due)
+	assert.Equal(t, todoStatus("done"), req.Status)
+	// event params override query params
+	assert.Equal(t, "event", req.Title)
+
+	// json values of the wrong type are coerced like form values
+	ctx = newBindContext("/", nil, Event{Params: json.RawMessage(`{"page":"3","due":"2023-04-05T10:30"}`)})
+	req = todoReq{}
+	assert.NoError(t, ctx.Bind(&req))
+	assert.Equal(t, 3, req.Page)
+	assert.Equal(t, time.Date(2023, 4, 5, 10, 30, 0, 0, time.UTC), req.Due)
+
+	urlValues, _ := json.Marshal(map[string][]string{"status": {"unknown"}})
+	ctx = newBindContext("/", PathParams{"id": "not-a-uuid"}, Event{Params: urlValues, IsForm: true})
+	err := ctx.BindAndValidate(&todoReq{})
+	fields, ok := err.(*firErrors.Fields)
+	if assert.True(t, ok, "expected field errors, got %v", err) {
+		assert.Equal(t, map[string]string{"id": "id must be a valid value"}, fields.Map())
+	}
+
+	ctx = newBindContext("/", nil, Event{Params: urlValues, IsForm: true})
+	err = ctx.BindAndValidate(&todoReq{})
+	fields, ok = err.(*firErrors.Fields)
+	if assert.True(t, ok, "expected field errors, got %v", err) {
+		assert.Equal(t, map[string]string{"status": "status must be a valid value"}, fields.Map())
+	}
+}
+
+func TestBindJSONLargeNumbers(t *testing.T) {
+	var req struct {
+		ID    int     `json:"id"`
+		Count int     `json:"count"`
+		Ratio float64 `json:"ratio"`
+	}
+	binder := NewSchemaBinder(NewFormDecoder())
+	assert.NoError(t, binder.BindJSON(&req, []byte(`{"id":"7","count":1000000,"ratio":0.000001}`)))
+	assert.Equal(t, 7, req.ID)
+	assert.Equal(t, 1000000, req.Count)
+	assert.Equal(t, 0.000001, req.Ratio)
+}
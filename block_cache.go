@@ -0,0 +1,189 @@
+package fir
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithBlockCache is an option to cache the blocks rendered for the events of a route, keyed by the block name and
+// a hash of the event data, so that an event published to many clients is rendered once.
+// size is the max number of rendered blocks cached per route and ttl is how long they are cached, 0 meaning no expiry.
+// Blocks rendered for error states are not cached since they depend on the errors of each client's page.
+// Only event data made of maps, slices and primitives is cached, e.g. the data set by ctx.Data with a map or a flat struct.
+func WithBlockCache(size int, ttl time.Duration) ControllerOption {
+	return func(o *opt) {
+		o.blockCacheSize = size
+		o.blockCacheTTL = ttl
+	}
+}
+
+// blockCache is a least recently used cache of rendered blocks with an expiry.
+// Concurrent renders of the same block and data wait for the first one.
+type blockCache struct {
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List
+	calls   map[string]*blockRender
+	sync.Mutex
+}
+
+type blockCacheEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+type blockRender struct {
+	value string
+	err   error
+	done  chan struct{}
+}
+
+func newBlockCache(size int, ttl time.Duration) *blockCache {
+	return &blockCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		calls:   make(map[string]*blockRender),
+	}
+}
+
+// blockCacheKey returns the key of a block rendered with the data. ok is false if the data can't be fingerprinted.
+func blockCacheKey(templateName string, data any) (key string, ok bool) {
+	h := sha256.New()
+	if !writeFingerprint(h, data) {
+		return "", false
+	}
+	return templateName + ":" + hex.EncodeToString(h.Sum(nil)), true
+}
+
+// writeFingerprint writes the type and value of the data to the hash. Only maps, slices and primitives are supported
+// since other values can render differently with the same encoding e.g. structs with unexported fields or methods.
+func writeFingerprint(h hash.Hash, data any) bool {
+	switch v := data.(type) {
+	case nil:
+		io.WriteString(h, "nil;")
+	case string:
+		fmt.Fprintf(h, "string:%d:%s;", len(v), v)
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		fmt.Fprintf(h, "%T:%v;", v, v)
+	case routeData:
+		return writeMapFingerprint(h, "routeData", v)
+	case *routeData:
+		if v == nil {
+			return false
+		}
+		return writeMapFingerprint(h, "*routeData", *v)
+	case map[string]any:
+		return writeMapFingerprint(h, "map", v)
+	case []any:
+		fmt.Fprintf(h, "slice:%d;", len(v))
+		for _, item := range v {
+			if !writeFingerprint(h, item) {
+				return false
+			}
+		}
+	case []string:
+		fmt.Fprintf(h, "[]string:%d;", len(v))
+		for _, item := range v {
+			writeFingerprint(h, item)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+func writeMapFingerprint(h hash.Hash, typeName string, m map[string]any) bool {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	fmt.Fprintf(h, "%s:%d;", typeName, len(m))
+	for _, k := range keys {
+		writeFingerprint(h, k)
+		if !writeFingerprint(h, m[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// render returns the cached block or renders and caches it.
+func (c *blockCache) render(key string, render func() (string, error)) (string, error) {
+	c.Lock()
+	if value, ok := c.get(key); ok {
+		c.Unlock()
+		return value, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.Unlock()
+		<-call.done
+		return call.value, call.err
+	}
+	call := &blockRender{done: make(chan struct{})}
+	c.calls[key] = call
+	c.Unlock()
+
+	call.value, call.err = render()
+
+	c.Lock()
+	delete(c.calls, key)
+	if call.err == nil {
+		c.set(key, call.value)
+	}
+	c.Unlock()
+	close(call.done)
+	return call.value, call.err
+}
+
+func (c *blockCache) get(key string) (string, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := el.Value.(*blockCacheEntry)
+	if !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		c.lru.Remove(el)
+		delete(c.entries, key)
+		return "", false
+	}
+	c.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *blockCache) set(key, value string) {
+	entry := &blockCacheEntry{key: key, value: value}
+	if c.ttl > 0 {
+		entry.expires = time.Now().Add(c.ttl)
+	}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*blockCacheEntry).key)
+	}
+}
+
+// clear drops the cached blocks when the templates are parsed again
+func (c *blockCache) clear() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+}
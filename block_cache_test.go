@@ -0,0 +1,125 @@
+package fir
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockCacheEviction(t *testing.T) {
+	c := newBlockCache(2, 0)
+	render := func(v string) func() (string, error) {
+		return func() (string, error) { return v, nil }
+	}
+	c.render("a", render("1"))
+	c.render("b", render("2"))
+	// a is the most recently used
+	v, _ := c.render("a", render("x"))
+	assert.Equal(t, "1", v)
+	c.render("c", render("3"))
+
+	c.Lock()
+	_, okA := c.get("a")
+	_, okB := c.get("b")
+	c.Unlock()
+	assert.True(t, okA)
+	assert.False(t, okB)
+
+	c = newBlockCache(2, time.Millisecond)
+	c.render("a", render("1"))
+	time.Sleep(5 * time.Millisecond)
+	v, _ = c.render("a", render("2"))
+	assert.Equal(t, "2", v)
+}
+
+func TestBlockCacheRendersOnce(t *testing.T) {
+	var renders int32
+	c := NewController("test", WithPublicDir("."), WithBlockCache(100, time.Minute))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("ticker"),
+			Content(`<div @fir:tick:ok::count="">{{block "count" .}}{{rendered}}<span>{{.count}}</span>{{end}}</div>`),
+			FuncMap(template.FuncMap{"rendered": func() string {
+				atomic.AddInt32(&renders, 1)
+				return ""
+			}}),
+			OnEvent("tick", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	ctx := RouteContext{request: httptest.NewRequest(http.MethodGet, "/", nil), route: c.(*controller).routes["ticker"]}
+	id := "tick"
+	publish := func(count int) []string {
+		var details []string
+		for _, ev := range renderDOMEvents(ctx, pubsub.Event{ID: &id, State: eventstate.OK, Detail: routeData{"count": count}}) {
+			details = append(details, ev.Detail.(string))
+		}
+		return details
+	}
+
+	// the subscribers of a broadcast render the event concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, []string{"<span>1</span>"}, publish(1))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&renders))
+
+	assert.Equal(t, []string{"<span>2</span>"}, publish(2))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&renders))
+}
+
+type secretItem struct {
+	secret string
+}
+
+func (i secretItem) Secret() string {
+	return i.secret
+}
+
+func TestBlockCacheSkipsOpaqueData(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithBlockCache(100, time.Minute))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("secrets"),
+			Content(`<div @fir:show:ok::item="">{{block "item" .}}<span>{{.item.Secret}}</span>{{end}}</div>`),
+			OnEvent("show", func(ctx RouteContext) error {
+				var params struct {
+					Secret string `json:"secret"`
+				}
+				if err := ctx.Bind(&params); err != nil {
+					return err
+				}
+				// secretItem encodes to {} for every secret
+				return ctx.Data(map[string]any{"item": secretItem{secret: params.Secret}})
+			}),
+		}
+	})
+	show := func(secret string) string {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_id":"show","params":{"secret":"`+secret+`"}}`))
+		r.Header.Set("X-FIR-MODE", "event")
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+	assert.Contains(t, show("a"), `"detail":"\u003cspan\u003ea\u003c/span\u003e"`)
+	assert.Contains(t, show("b"), `"detail":"\u003cspan\u003eb\u003c/span\u003e"`)
+
+	_, ok := blockCacheKey("item", routeData{"item": secretItem{}})
+	assert.False(t, ok)
+	a, _ := blockCacheKey("item", routeData{"count": 1})
+	b, _ := blockCacheKey("item", routeData{"count": "1"})
+	assert.NotEqual(t, a, b)
+}
@@ -1,12 +1,15 @@
 package fir
 
 import (
+	"context"
 	"embed"
 	"flag"
+	"html/template"
+	"io/fs"
 	"log"
 	"net/http"
-	"reflect"
-	"strings"
+	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -20,9 +23,16 @@ import (
 
 // Controller is an interface which encapsulates a group of views. It routes requests to the appropriate view.
 // It routes events to the appropriate view. It also provides a way to register views.
+// The controller is itself an http.Handler which dispatches requests to the routes registered with Handle or HandleFunc.
 type Controller interface {
 	Route(route Route) http.HandlerFunc
 	RouteFunc(options RouteFunc) http.HandlerFunc
+	Routes() []RouteInfo
+	Shutdown(ctx context.Context) error
+	Emit(ctx context.Context, routeID string, event Event, targets ...PublishTarget) error
+	Validate() Diagnostics
+	Router
+	http.Handler
 }
 
 type opt struct {
@@ -37,27 +47,37 @@ type opt struct {
 	watchExts            []string
 	publicDir            string
 	developmentMode      bool
-	embedFS              embed.FS
+	embedFS              fs.FS
 	hasEmbedFS           bool
 	readFile             readFileFunc
 	pubsub               pubsub.Adapter
 	appName              string
 	formDecoder          *schema.Decoder
 	cookieName           string
-	secureCookie         *securecookie.SecureCookie
+	cookieCodecs         []securecookie.Codec
 	cache                *cache.Cache
+	routeManifestPath    string
+	transport            Transport
+	sessionStore         SessionStore
+	validator            *validator.Validate
+	maxUploadSize        int64
+	binder               Binder
+	eventTimeout         time.Duration
+	validateTemplates    bool
+	templateDiagnostics  bool
+	templateEngine       TemplateEngine
+	controllerFuncMap    template.FuncMap
+	csrfFieldName        string
+	csrfToken            func(r *http.Request) string
+	layoutParents        map[string]LayoutParent
+	blockCacheSize       int
+	blockCacheTTL        time.Duration
 }
 
 // ControllerOption is an option for the controller.
 type ControllerOption func(*opt)
 
-func WithSecureCookie(s *securecookie.SecureCookie) ControllerOption {
-	return func(o *opt) {
-		o.secureCookie = s
-	}
-}
-
-// WithCookieName is an option to set the cookie session name for the controller.
+// WithCookieName is an option to set the name used to encode the page session ids rendered by the controller.
 func WithCookieName(name string) ControllerOption {
 	return func(o *opt) {
 		o.cookieName = name
@@ -107,7 +127,8 @@ func WithPublicDir(path string) ControllerOption {
 	}
 }
 
-// WithFormDecoder is an option to set the form decoder(gorilla/schema) for the controller.
+// WithFormDecoder is an option to set the form decoder(gorilla/schema) used by the default binder.
+// Start from NewFormDecoder to keep the default converters.
 func WithFormDecoder(decoder *schema.Decoder) ControllerOption {
 	return func(o *opt) {
 		o.formDecoder = decoder
@@ -153,45 +174,55 @@ func DevelopmentMode(enable bool) ControllerOption {
 	}
 }
 
+// WithLayoutParent is an option to nest a layout inside a parent layout. The layout must define the template
+// named contentName which is rendered by the parent layout. Layouts can be nested to any depth
+// e.g. a settings layout inside an app layout inside a base shell. The error layouts of the routes are nested the same way.
+func WithLayoutParent(layout, parent, contentName string) ControllerOption {
+	return func(o *opt) {
+		if o.layoutParents == nil {
+			o.layoutParents = make(map[string]LayoutParent)
+		}
+		o.layoutParents[filepath.Clean(layout)] = LayoutParent{Layout: parent, ContentName: contentName}
+	}
+}
+
 // NewController creates a new controller.
 func NewController(name string, options ...ControllerOption) Controller {
 	if name == "" {
 		panic("controller name is required")
 	}
 
-	formDecoder := schema.NewDecoder()
-	formDecoder.IgnoreUnknownKeys(true)
-	formDecoder.SetAliasTag("json")
-
-	validate := validator.New()
-	// register function to get tag name from json tags.
-	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
-		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
-		if name == "-" {
-			return ""
-		}
-		return name
-	})
-
 	o := &opt{
 		channelFunc:       defaultChannelFunc,
 		websocketUpgrader: websocket.Upgrader{EnableCompression: true},
+		transport:         Websocket,
 		watchExts:         defaultWatchExtensions,
 		pubsub:            pubsub.NewInmem(),
 		appName:           name,
-		formDecoder:       formDecoder,
+		formDecoder:       NewFormDecoder(),
 		cookieName:        "_fir_session_",
-		secureCookie: securecookie.New(
+		cookieCodecs: securecookie.CodecsFromPairs(
 			securecookie.GenerateRandomKey(64),
 			securecookie.GenerateRandomKey(32),
 		),
-		cache: cache.New(5*time.Minute, 10*time.Minute),
+		cache:          cache.New(5*time.Minute, 10*time.Minute),
+		validator:      newValidator(),
+		maxUploadSize:  defaultMaxUploadSize,
+		templateEngine: NewHTMLTemplateEngine(),
 	}
 
 	for _, option := range options {
 		option(o)
 	}
 
+	if o.binder == nil {
+		o.binder = NewSchemaBinder(o.formDecoder)
+	}
+
+	if o.sessionStore == nil {
+		o.sessionStore = NewCookieSessionStore(o.cookieName+"data_", o.cookieCodecs...)
+	}
+
 	if o.publicDir == "" {
 		var publicDir string
 		publicDirUsage := "public directory that contains the html template files."
@@ -202,10 +233,14 @@ func NewController(name string, options ...ControllerOption) Controller {
 	}
 
 	c := &controller{
-		opt:    *o,
-		name:   name,
-		routes: make(map[string]*route),
+		opt:     *o,
+		name:    name,
+		routes:  make(map[string]*route),
+		quit:    make(chan struct{}),
+		conns:   make(map[*websocketConn]struct{}),
+		pollers: make(map[string]*poller),
 	}
+	c.root = &routeGroup{cntrl: c}
 	if c.developmentMode {
 		log.Println("controller starting in developer mode ...", c.developmentMode)
 		c.debugLog = true
@@ -214,9 +249,18 @@ func NewController(name string, options ...ControllerOption) Controller {
 	}
 
 	if c.enableWatch {
+		c.workers.Add(1)
 		go watchTemplates(c)
 	}
 
+	if janitor, ok := c.sessionStore.(sessionJanitor); ok {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			janitor.runJanitor(c.quit)
+		}()
+	}
+
 	if c.hasEmbedFS {
 		c.readFile = readFileFS(c.embedFS)
 		log.Println("read template files embedded in the binary")
@@ -228,45 +272,67 @@ func NewController(name string, options ...ControllerOption) Controller {
 }
 
 type controller struct {
-	name   string
-	routes map[string]*route
+	name     string
+	routes   map[string]*route
+	matchers []*routeMatcher
+	root     *routeGroup
 	opt
+	sync.RWMutex
+
+	// shutdown
+	quit         chan struct{}
+	shuttingDown bool
+	conns        map[*websocketConn]struct{}
+	pollers      map[string]*poller
+	workers      sync.WaitGroup
+
+	// fingerprinted asset urls
+	assets sync.Map
 }
 
-var defaultRouteOpt = &routeOpt{
-	id:                shortuuid.New(),
-	content:           "Hello Fir App!",
-	layoutContentName: "content",
-	partials:          []string{"./routes/partials"},
-	funcMap:           defaultFuncMap(),
-	extensions:        []string{".gohtml", ".gotmpl", ".html", ".tmpl"},
-	eventSender:       make(chan Event),
-	onLoad: func(ctx RouteContext) error {
-		return nil
-	},
+func newRouteOpt() *routeOpt {
+	return &routeOpt{
+		id:                shortuuid.New(),
+		content:           "Hello Fir App!",
+		layoutContentName: "content",
+		partials:          []string{"./routes/partials"},
+		funcMap:           make(template.FuncMap),
+		extensions:        []string{".gohtml", ".gotmpl", ".html", ".tmpl"},
+		onLoad: func(ctx RouteContext) error {
+			return nil
+		},
+	}
 }
 
 // Route returns an http.HandlerFunc that renders the route
 func (c *controller) Route(route Route) http.HandlerFunc {
-	for _, option := range route.Options() {
-		option(defaultRouteOpt)
-	}
-
-	// create new route
-	r := newRoute(c, defaultRouteOpt)
-	// register route in the controller
-	c.routes[r.id] = r
-	return r.ServeHTTP
+	return c.addRoute(c.root, route.Options()).ServeHTTP
 }
 
 // RouteFunc returns an http.HandlerFunc that renders the route
 func (c *controller) RouteFunc(opts RouteFunc) http.HandlerFunc {
-	for _, option := range opts() {
-		option(defaultRouteOpt)
+	return c.addRoute(c.root, opts()).ServeHTTP
+}
+
+func (c *controller) addRoute(group *routeGroup, options RouteOptions) *route {
+	routeOpt := newRouteOpt()
+	for _, option := range options {
+		option(routeOpt)
 	}
 	// create new route
-	r := newRoute(c, defaultRouteOpt)
+	r := newRoute(c, routeOpt)
+	r.group = group
+	if c.validateTemplates {
+		if diagnostics := r.validate(); len(diagnostics) > 0 {
+			panic(diagnostics)
+		}
+	}
 	// register route in the controller
+	c.Lock()
 	c.routes[r.id] = r
-	return r.ServeHTTP
+	c.Unlock()
+	if r.eventSender != nil && c.track() {
+		go c.forwardServerEvents(r)
+	}
+	return r
 }
@@ -0,0 +1,116 @@
+package fir
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gorilla/securecookie"
+)
+
+// WithSecureCookie is an option to set the secure cookie used to encode the controller's cookies.
+func WithSecureCookie(s *securecookie.SecureCookie) ControllerOption {
+	return func(o *opt) {
+		o.cookieCodecs = []securecookie.Codec{s}
+	}
+}
+
+// WithCookieKeys is an option to set the hash and block key pairs used to encode the controller's cookies:
+// hashKey1, blockKey1, hashKey2, blockKey2 ... The first pair encodes new cookies and all the pairs are tried
+// to decode a cookie, so keys can be rotated by prepending a new pair and dropping the oldest one later.
+// A nil block key disables encryption for that pair. See securecookie.CodecsFromPairs.
+//
+// By default random keys are generated on start, so cookies don't survive a restart and can't be shared across instances.
+// Use CookieKeysFromEnv or CookieKeysFromFile to load persistent keys.
+func WithCookieKeys(keyPairs ...[]byte) ControllerOption {
+	return func(o *opt) {
+		o.cookieCodecs = securecookie.CodecsFromPairs(keyPairs...)
+	}
+}
+
+// CookieKeysFromEnv reads the cookie key pairs from the environment variable. See ParseCookieKeys for the format.
+func CookieKeysFromEnv(name string) ([][]byte, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil, fmt.Errorf("environment variable %s is not set", name)
+	}
+	return ParseCookieKeys(value)
+}
+
+// CookieKeysFromFile reads the cookie key pairs from the file. See ParseCookieKeys for the format.
+func CookieKeysFromFile(path string) ([][]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCookieKeys(string(data))
+}
+
+// ParseCookieKeys parses cookie key pairs separated by newlines or commas, primary pair first.
+// A pair is a base64 encoded hash key and an optional base64 encoded block key separated by a colon:
+//
+//	<hash-key>:<block-key>
+//	<old-hash-key>:<old-block-key>
+//
+// The block key must decode to 16, 24 or 32 bytes to select AES-128, AES-192 or AES-256. Empty lines and lines
+// starting with # are ignored. The result can be passed to WithCookieKeys.
+func ParseCookieKeys(value string) ([][]byte, error) {
+	var keyPairs [][]byte
+	entries := strings.FieldsFunc(value, func(r rune) bool {
+		return r == '\n' || r == ','
+	})
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		hashKey, err := decodeCookieKey(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash key: %w", err)
+		}
+		if len(hashKey) == 0 {
+			return nil, fmt.Errorf("hash key is empty")
+		}
+		var blockKey []byte
+		if len(parts) == 2 {
+			blockKey, err = decodeCookieKey(parts[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid block key: %w", err)
+			}
+			if len(blockKey) == 0 {
+				blockKey = nil
+			}
+		}
+		if blockKey != nil && len(blockKey) != 16 && len(blockKey) != 24 && len(blockKey) != 32 {
+			return nil, fmt.Errorf("invalid block key: length must be 16, 24 or 32 bytes, got %d", len(blockKey))
+		}
+		keyPairs = append(keyPairs, hashKey, blockKey)
+	}
+	if len(keyPairs) == 0 {
+		return nil, fmt.Errorf("no cookie keys found")
+	}
+	return keyPairs, nil
+}
+
+func decodeCookieKey(key string) ([]byte, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(key); err == nil {
+		return b, nil
+	}
+	return base64.URLEncoding.DecodeString(key)
+}
+
+// encodeCookie encodes the cookie value with the primary cookie codec
+func (o *opt) encodeCookie(name string, value any) (string, error) {
+	return securecookie.EncodeMulti(name, value, o.cookieCodecs...)
+}
+
+// decodeCookie decodes the cookie value trying all the cookie codecs
+func (o *opt) decodeCookie(name, value string, dst any) error {
+	return securecookie.DecodeMulti(name, value, dst, o.cookieCodecs...)
+}
@@ -0,0 +1,94 @@
+package fir
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/securecookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCookieKeys(t *testing.T) {
+	hashKey := securecookie.GenerateRandomKey(64)
+	blockKey := securecookie.GenerateRandomKey(32)
+	enc := base64.StdEncoding.EncodeToString
+
+	tests := []struct {
+		name    string
+		value   string
+		want    [][]byte
+		wantErr bool
+	}{
+		{
+			name:  "hash and block key",
+			value: enc(hashKey) + ":" + enc(blockKey),
+			want:  [][]byte{hashKey, blockKey},
+		},
+		{
+			name:  "hash key only",
+			value: enc(hashKey),
+			want:  [][]byte{hashKey, nil},
+		},
+		{
+			name:  "multiple pairs separated by newlines and commas with comments",
+			value: "# primary\n" + enc(hashKey) + ":" + enc(blockKey) + "\n\n" + enc(blockKey) + "," + enc(hashKey),
+			want:  [][]byte{hashKey, blockKey, blockKey, nil, hashKey, nil},
+		},
+		{
+			name:    "invalid block key length",
+			value:   enc(hashKey) + ":" + enc([]byte("short")),
+			wantErr: true,
+		},
+		{
+			name:    "invalid base64",
+			value:   "not base64!",
+			wantErr: true,
+		},
+		{
+			name:    "empty",
+			value:   "\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCookieKeys(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestCookieKeyRotation(t *testing.T) {
+	oldPair := [][]byte{securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32)}
+	newPair := [][]byte{securecookie.GenerateRandomKey(64), securecookie.GenerateRandomKey(32)}
+
+	oldOpt := &opt{}
+	WithCookieKeys(oldPair...)(oldOpt)
+	encoded, err := oldOpt.encodeCookie("test", "value")
+	assert.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "keys")
+	enc := base64.StdEncoding.EncodeToString
+	content := enc(newPair[0]) + ":" + enc(newPair[1]) + "\n" + enc(oldPair[0]) + ":" + enc(oldPair[1]) + "\n"
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0600))
+	keyPairs, err := CookieKeysFromFile(file)
+	assert.NoError(t, err)
+
+	rotatedOpt := &opt{}
+	WithCookieKeys(keyPairs...)(rotatedOpt)
+	var decoded string
+	assert.NoError(t, rotatedOpt.decodeCookie("test", encoded, &decoded))
+	assert.Equal(t, "value", decoded)
+
+	// new cookies are encoded with the primary pair
+	encoded, err = rotatedOpt.encodeCookie("test", "value")
+	assert.NoError(t, err)
+	assert.Error(t, oldOpt.decodeCookie("test", encoded, &decoded))
+}
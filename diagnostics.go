@@ -0,0 +1,227 @@
+package fir
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// builtinEvents are the dom events dispatched by the framework which can be bound without an event state e.g. @fir:flash.window
+var builtinEvents = []string{"flash", "redirect", "push-url", "replace-url"}
+
+// Diagnostic is a problem found in the templates of a route.
+type Diagnostic struct {
+	RouteID   string
+	File      string
+	Line      int
+	Attribute string
+	Reason    string
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.RouteID != "" {
+		fmt.Fprintf(&b, "route %s: ", d.RouteID)
+	}
+	if d.File != "" {
+		b.WriteString(d.File)
+		if d.Line > 0 {
+			fmt.Fprintf(&b, ":%d", d.Line)
+		}
+		b.WriteString(": ")
+	}
+	if d.Attribute != "" {
+		fmt.Fprintf(&b, "%s: ", d.Attribute)
+	}
+	b.WriteString(d.Reason)
+	return b.String()
+}
+
+// Diagnostics is the list of problems returned by Controller.Validate.
+type Diagnostics []Diagnostic
+
+func (ds Diagnostics) Error() string {
+	lines := make([]string, len(ds))
+	for i, d := range ds {
+		lines[i] = d.String()
+	}
+	return "template validation failed:\n" + strings.Join(lines, "\n")
+}
+
+// EnableTemplateValidation is an option to validate the templates of a route when it is registered.
+// Registering a route panics with the Diagnostics if a problem is found.
+func EnableTemplateValidation() ControllerOption {
+	return func(o *opt) {
+		o.validateTemplates = true
+	}
+}
+
+// WithTemplateDiagnostics is an option to record the template errors of a route instead of panicking when it is registered,
+// so that they are reported by Controller.Validate. A page whose templates failed to parse is served as a 500.
+func WithTemplateDiagnostics() ControllerOption {
+	return func(o *opt) {
+		o.templateDiagnostics = true
+	}
+}
+
+// Validate parses the templates of all the registered routes and returns the problems found in them:
+// template syntax errors (see WithTemplateDiagnostics), malformed event bindings and bindings to events which are not registered with OnEvent.
+func (c *controller) Validate() Diagnostics {
+	c.RLock()
+	routes := make([]*route, 0, len(c.routes))
+	for _, rt := range c.routes {
+		routes = append(routes, rt)
+	}
+	c.RUnlock()
+	sort.Slice(routes, func(i, j int) bool { return routes[i].id < routes[j].id })
+
+	var diagnostics Diagnostics
+	for _, rt := range routes {
+		diagnostics = append(diagnostics, rt.validate()...)
+	}
+	return diagnostics
+}
+
+func (rt *route) validate() Diagnostics {
+	rt.parseTemplates()
+	var diagnostics Diagnostics
+	rt.RLock()
+	if rt.parseErr != nil {
+		diagnostics = append(diagnostics, templateErrorDiagnostic(rt.parseErr))
+	}
+	rt.RUnlock()
+
+	for _, fi := range rt.templateFiles() {
+		if fi.err != nil {
+			diagnostics = append(diagnostics, Diagnostic{File: fi.name, Reason: fi.err.Error()})
+			continue
+		}
+		fi = query(fi)
+		diagnostics = append(diagnostics, fi.diagnostics...)
+		for _, binding := range fi.bindings {
+			if _, ok := rt.onEvents[strings.ToLower(binding.event)]; ok {
+				continue
+			}
+			diagnostics = append(diagnostics, Diagnostic{
+				File:      binding.file,
+				Line:      binding.line,
+				Attribute: binding.attribute,
+				Reason:    fmt.Sprintf("event %s is not registered with OnEvent", binding.event),
+			})
+		}
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	for i := range diagnostics {
+		diagnostics[i].RouteID = rt.id
+	}
+	return diagnostics
+}
+
+// templateFiles reads the layouts and their parents, contents and partials of the route. Inline templates are named after the route option.
+func (rt *route) templateFiles() []fileInfo {
+	var files []fileInfo
+	seen := make(map[string]bool)
+	add := func(option, content string) {
+		if content == "" {
+			return
+		}
+		contentPath := filepath.Join(rt.publicDir, content)
+		if isFileOrString(contentPath, rt.routeOpt) {
+			name := fmt.Sprintf("%s(inline)", option)
+			if seen[name+content] {
+				return
+			}
+			seen[name+content] = true
+			files = append(files, fileInfo{name: name, content: []byte(content)})
+			return
+		}
+		for _, file := range find(rt.routeOpt, contentPath, rt.extensions) {
+			if seen[file] {
+				continue
+			}
+			seen[file] = true
+			_, b, err := rt.readFile(file)
+			files = append(files, fileInfo{name: file, content: b, err: err})
+		}
+	}
+	addLayout := func(option, layout string) {
+		// a cycle is reported as a template error
+		chain, _ := layoutChain(rt.routeOpt, layout)
+		for _, link := range chain {
+			add(option, link.layout)
+		}
+	}
+	addLayout("Layout", rt.layout)
+	add("Content", rt.content)
+	addLayout("ErrorLayout", rt.errorLayout)
+	add("ErrorContent", rt.errorContent)
+	for _, partial := range rt.partials {
+		add("Partials", partial)
+	}
+	return files
+}
+
+// eventBinding is a well formed event binding declared in a template.
+type eventBinding struct {
+	event     string
+	file      string
+	line      int
+	attribute string
+}
+
+var templateErrorRegex = regexp.MustCompile(`^template: (.+?):(\d+): (.*)$`)
+
+// templateErrorDiagnostic extracts the file and line of a html/template parse error
+func templateErrorDiagnostic(err error) Diagnostic {
+	match := templateErrorRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return Diagnostic{Reason: err.Error()}
+	}
+	line, _ := strconv.Atoi(match[2])
+	return Diagnostic{File: match[1], Line: line, Reason: match[3]}
+}
+
+func logDiagnostics(diagnostics []Diagnostic) {
+	for _, d := range diagnostics {
+		klog.Errorf("error: %v\n", d)
+	}
+}
+
+// lineFinder returns the line numbers of attributes in the order in which they appear in the content.
+type lineFinder struct {
+	content []byte
+	offset  int
+}
+
+func newLineFinder(content []byte) *lineFinder {
+	// the html parser lower cases attribute names
+	return &lineFinder{content: bytes.ToLower(content)}
+}
+
+func (l *lineFinder) find(attribute string) int {
+	needle := []byte(attribute)
+	i := bytes.Index(l.content[l.offset:], needle)
+	if i < 0 {
+		// the attributes of a node are not always visited in source order
+		i = bytes.Index(l.content, needle)
+		if i < 0 {
+			return 0
+		}
+	} else {
+		i += l.offset
+	}
+	l.offset = i + len(needle)
+	return bytes.Count(l.content[:i], []byte("\n")) + 1
+}
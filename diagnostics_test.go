@@ -0,0 +1,128 @@
+package fir
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerValidate(t *testing.T) {
+	dir := t.TempDir()
+	page := `<div>
+	<form @fir:create:ok="$el.reset()" @fir:creat:ok::list="">
+		<span @fir:create:done:oops="">{{.title}}</span>
+	</form>
+	<p @fir:flash.window="show = true"></p>
+</div>`
+	err := os.WriteFile(filepath.Join(dir, "page.html"), []byte(page), 0o644)
+	assert.NoError(t, err)
+
+	c := NewController("test", WithPublicDir(dir), WithTemplateDiagnostics())
+	c.RouteFunc(func() RouteOptions {
+		return RouteOptions{
+			ID("page"),
+			Content("page.html"),
+			OnEvent("create", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	c.RouteFunc(func() RouteOptions {
+		return RouteOptions{
+			ID("broken"),
+			Content(`<div>{{if .ok}}</div>`),
+		}
+	})
+
+	diagnostics := c.Validate()
+	if !assert.Len(t, diagnostics, 3) {
+		t.FailNow()
+	}
+
+	assert.Equal(t, "broken", diagnostics[0].RouteID)
+	assert.Contains(t, diagnostics[0].Reason, "unexpected EOF")
+
+	file := filepath.Join(dir, "page.html")
+	// the diagnostics of a file are sorted by line
+	assert.Equal(t, Diagnostic{
+		RouteID:   "page",
+		File:      file,
+		Line:      2,
+		Attribute: "@fir:creat:ok::list",
+		Reason:    "event creat is not registered with OnEvent",
+	}, diagnostics[1])
+	assert.Equal(t, Diagnostic{
+		RouteID:   "page",
+		File:      file,
+		Line:      3,
+		Attribute: "@fir:create:done:oops",
+		Reason:    eventFormatError("create:done:oops"),
+	}, diagnostics[2])
+}
+
+func TestEnableTemplateValidation(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), EnableTemplateValidation())
+	assert.NotPanics(t, func() {
+		c.RouteFunc(func() RouteOptions {
+			return RouteOptions{
+				ID("ok"),
+				Content(`<div @fir:inc:ok="">{{.count}}</div>`),
+				OnEvent("inc", func(ctx RouteContext) error { return nil }),
+			}
+		})
+	})
+	assert.PanicsWithError(t, Diagnostics{{
+		RouteID:   "typo",
+		File:      "Content(inline)",
+		Line:      1,
+		Attribute: "@fir:ic:ok",
+		Reason:    "event ic is not registered with OnEvent",
+	}}.Error(), func() {
+		c.RouteFunc(func() RouteOptions {
+			return RouteOptions{
+				ID("typo"),
+				Content(`<div @fir:ic:ok="">{{.count}}</div>`),
+				OnEvent("inc", func(ctx RouteContext) error { return nil }),
+			}
+		})
+	})
+}
+
+type failingEngine struct {
+	parses int
+}
+
+func (e *failingEngine) Parse(config TemplateConfig) (Template, error) {
+	e.parses++
+	return nil, errors.New("template: page.html:3: unexpected EOF")
+}
+
+func TestTemplateErrors(t *testing.T) {
+	assert.Panics(t, func() {
+		c := NewController("test", WithPublicDir("."))
+		c.RouteFunc(func() RouteOptions {
+			return RouteOptions{ID("broken"), Content(`<div>{{if .ok}}</div>`)}
+		})
+	})
+
+	engine := &failingEngine{}
+	c := NewController("test", WithPublicDir("."), WithTemplateEngine(engine), WithTemplateDiagnostics())
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{ID("broken"), Content("page.html")}
+	})
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	}
+	// the failed parse is not retried when the templates are cached
+	assert.Equal(t, 1, engine.parses)
+
+	diagnostics := c.Validate()
+	if assert.Len(t, diagnostics, 1) {
+		assert.Equal(t, Diagnostic{RouteID: "broken", File: "page.html", Line: 3, Reason: "unexpected EOF"}, diagnostics[0])
+	}
+}
@@ -0,0 +1,87 @@
+package fir
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// Emit runs the route's handler for a server initiated event, e.g. a notification or a background job update,
+// and publishes the result to the targets. The handler runs once regardless of the number of clients and
+// the event templates are rendered for each subscribed client, on every instance sharing the controller's pubsub adapter.
+// The result is broadcast to all the clients of the route if no targets are given. The events emitted to a channel
+// are delivered to each client in the order they were emitted.
+//
+// The handler's ctx.Request() is a synthetic POST / request carrying ctx, so values like the user are passed
+// through ctx, e.g. context.WithValue(ctx, fir.UserKey, userID) for ctx.GetUserFromContext.
+//
+// Errors returned by the handler are returned to the caller instead of being published.
+func (c *controller) Emit(ctx context.Context, routeID string, event Event, targets ...PublishTarget) error {
+	c.RLock()
+	rt, ok := c.routes[routeID]
+	c.RUnlock()
+	if !ok {
+		return fmt.Errorf("route %s not found", routeID)
+	}
+	onEventFunc, ok := rt.onEvents[strings.ToLower(event.ID)]
+	if !ok {
+		return fmt.Errorf("route %s: onEvent handler for %s not found", routeID, event.ID)
+	}
+	if len(targets) == 0 {
+		targets = []PublishTarget{Broadcast()}
+	}
+
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, "/", nil)
+	if err != nil {
+		return err
+	}
+	eventCtx := RouteContext{
+		event:    event,
+		request:  r,
+		response: newDiscardResponseWriter(),
+		route:    rt,
+		// server events don't belong to a browser session
+		session: newSession(nil, r, ""),
+		targets: newPublishTargets(),
+	}
+	eventCtx.targets.add(targets...)
+	result := eventCtx.call(onEventFunc)
+	if result != nil && !isRouteData(result) {
+		return result
+	}
+	publishToTargets(ctx, eventCtx, resultEvent(eventCtx, result))
+	return nil
+}
+
+// forwardServerEvents emits the events sent on the route's EventSender channel until the channel is closed or the controller shuts down
+func (c *controller) forwardServerEvents(rt *route) {
+	defer c.workers.Done()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case event, ok := <-rt.eventSender:
+			if !ok {
+				return
+			}
+			c.emitServerEvent(rt, event)
+		}
+	}
+}
+
+// emitServerEvent emits an event sent on the route's EventSender channel. A panicking handler is logged
+// instead of crashing the process since there is no request to fail.
+func (c *controller) emitServerEvent(rt *route, event Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			klog.Errorf("[EventSender] route %s, event %s: handler panicked: %v\n%s", rt.id, event.ID, r, debug.Stack())
+		}
+	}()
+	if err := c.Emit(context.Background(), rt.id, event); err != nil {
+		klog.Errorf("[EventSender] route %s, event %s: %v\n", rt.id, event.ID, err)
+	}
+}
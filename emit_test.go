@@ -0,0 +1,119 @@
+package fir
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitFansOutToAllClients(t *testing.T) {
+	var calls int32
+	sender := make(chan Event)
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("ticker"),
+			Content(`<div @fir:tick:ok::count="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			EventSender(sender),
+			OnEvent("tick", func(ctx RouteContext) error {
+				atomic.AddInt32(&calls, 1)
+				var req struct {
+					Count int `json:"count"`
+				}
+				if err := ctx.Bind(&req); err != nil {
+					return err
+				}
+				return ctx.KV("count", req.Count)
+			}),
+		}
+	})
+	// a user per connection so that each connection's subscriptions can be awaited
+	c.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserKey, r.URL.Query().Get("user"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	var conns []*websocket.Conn
+	for i := 0; i < 3; i++ {
+		user := fmt.Sprintf("user%d", i)
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?user="+user, nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+		// the user's channel is subscribed after the broadcast channel. see subscribeRoutes
+		for !c.(*controller).pubsub.HasSubscribers(context.Background(), User(user).channel("ticker")) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	const ticks = 20
+	assert.NoError(t, c.Emit(context.Background(), "ticker", NewEvent("tick", map[string]int{"count": 1})))
+	for i := 2; i <= ticks; i++ {
+		sender <- NewEvent("tick", map[string]int{"count": i})
+	}
+
+	for _, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		// the events are delivered in the order they were emitted
+		for i := 1; i <= ticks; i++ {
+			_, message, err := conn.ReadMessage()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(message), fmt.Sprintf(`\u003cspan\u003e%d\u003c/span\u003e`, i))
+		}
+	}
+	// the handler runs once per event, not once per client
+	assert.Equal(t, int32(ticks), atomic.LoadInt32(&calls))
+
+	assert.Error(t, c.Emit(context.Background(), "unknown", NewEvent("tick", nil)))
+	assert.Error(t, c.Emit(context.Background(), "ticker", NewEvent("unknown", nil)))
+}
+
+func TestEmitUserAndPanickingSender(t *testing.T) {
+	var users []string
+	var mu sync.Mutex
+	sender := make(chan Event)
+	c := NewController("test", WithPublicDir("."))
+	defer c.Shutdown(context.Background())
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("inbox"),
+			Content("inbox"),
+			EventSender(sender),
+			OnEvent("notify", func(ctx RouteContext) error {
+				// panics without a user in the context
+				user := ctx.GetUserFromContext()
+				mu.Lock()
+				defer mu.Unlock()
+				users = append(users, user)
+				return nil
+			}),
+		}
+	})
+
+	assert.NoError(t, c.Emit(context.WithValue(context.Background(), UserKey, "u1"), "inbox", NewEvent("notify", nil)))
+
+	// a panicking handler doesn't stop the forwarding of the next events
+	sender <- NewEvent("notify", nil)
+	sender <- NewEvent("notify", nil)
+	sender <- NewEvent("unknown", nil)
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"u1"}, users)
+}
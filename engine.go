@@ -0,0 +1,153 @@
+package fir
+
+import (
+	"html/template"
+	"io"
+	"io/fs"
+	"sort"
+)
+
+// TemplateConfig is the template configuration of a route's page or error page passed to the TemplateEngine.
+type TemplateConfig struct {
+	PublicDir         string
+	Layout            string
+	Content           string
+	LayoutContentName string
+	Partials          []string
+	Extensions        []string
+	FuncMap           template.FuncMap
+	// FS is the embedded file system set by WithEmbedFS in which the template files are looked up. nil means the disk.
+	FS fs.FS
+	// ReadFile reads a template file from disk or the embedded file system and returns its base name and content
+	ReadFile func(string) (string, []byte, error)
+	// LayoutParents are the parents of the nested layouts set by WithLayoutParent keyed by the cleaned layout path
+	LayoutParents map[string]LayoutParent
+}
+
+// TemplateEngine parses the templates of a route.
+// The rendered html goes through the same event binding and dom event pipeline irrespective of the engine.
+type TemplateEngine interface {
+	Parse(config TemplateConfig) (Template, error)
+}
+
+// Template is a parsed page of a route.
+type Template interface {
+	// Execute renders the page
+	Execute(w io.Writer, data any) error
+	// ExecuteBlock renders a named block of the page for an event
+	ExecuteBlock(w io.Writer, name string, data any) error
+	// Blocks returns the names of the blocks defined in the page
+	Blocks() []string
+	// EventBindings returns the blocks bound to the events of the page keyed by event id and state e.g. create:ok.
+	// A binding without a block e.g. @fir:create:ok is listed as "-".
+	EventBindings() map[string][]string
+}
+
+// WithTemplateEngine is an option to set the engine which parses the templates of the controller's routes.
+func WithTemplateEngine(engine TemplateEngine) ControllerOption {
+	return func(o *opt) {
+		o.templateEngine = engine
+	}
+}
+
+// NewHTMLTemplateEngine returns the default html/template engine.
+func NewHTMLTemplateEngine() TemplateEngine {
+	return htmlTemplateEngine{}
+}
+
+type htmlTemplateEngine struct{}
+
+func (htmlTemplateEngine) Parse(config TemplateConfig) (Template, error) {
+	var opt routeOpt
+	opt.publicDir = config.PublicDir
+	opt.layout = config.Layout
+	opt.content = config.Content
+	opt.layoutContentName = config.LayoutContentName
+	opt.partials = config.Partials
+	opt.extensions = config.Extensions
+	opt.funcMap = config.FuncMap
+	opt.readFile = config.ReadFile
+	opt.embedFS = config.FS
+	opt.hasEmbedFS = config.FS != nil
+	opt.layoutParents = config.LayoutParents
+
+	tmpl, evt, err := parseTemplate(opt)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Option("missingkey=zero")
+	return &htmlTemplate{tmpl: tmpl, eventTemplates: evt}, nil
+}
+
+type htmlTemplate struct {
+	tmpl           *template.Template
+	eventTemplates eventTemplates
+}
+
+func (t *htmlTemplate) Execute(w io.Writer, data any) error {
+	return t.tmpl.Execute(w, data)
+}
+
+func (t *htmlTemplate) ExecuteBlock(w io.Writer, name string, data any) error {
+	return t.tmpl.ExecuteTemplate(w, name, data)
+}
+
+func (t *htmlTemplate) Blocks() []string {
+	var blocks []string
+	for _, tmpl := range t.tmpl.Templates() {
+		blocks = append(blocks, tmpl.Name())
+	}
+	return blocks
+}
+
+func (t *htmlTemplate) EventBindings() map[string][]string {
+	bindings := make(map[string][]string)
+	for eventID, templates := range t.eventTemplates {
+		var names []string
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		bindings[eventID] = names
+	}
+	return bindings
+}
+
+// templateConfig returns the configuration of the route's page or error page.
+// The error page falls back to the route's layout if an error layout is not set.
+func (rt *route) templateConfig(errorPage bool) TemplateConfig {
+	config := TemplateConfig{
+		PublicDir:         rt.publicDir,
+		Layout:            rt.layout,
+		Content:           rt.content,
+		LayoutContentName: rt.layoutContentName,
+		Partials:          rt.partials,
+		Extensions:        rt.extensions,
+		FuncMap:           rt.funcMap,
+		ReadFile:          rt.readFile,
+		LayoutParents:     rt.layoutParents,
+	}
+	if rt.hasEmbedFS {
+		config.FS = rt.embedFS
+	}
+	if errorPage {
+		config.Content = rt.errorContent
+		if rt.errorLayout != "" {
+			config.Layout = rt.errorLayout
+			config.LayoutContentName = rt.errorLayoutContentName
+		}
+	}
+	return config
+}
+
+func eventTemplatesOf(bindings map[string][]string) eventTemplates {
+	evt := make(eventTemplates)
+	for eventID, names := range bindings {
+		templates := make(eventTemplate)
+		for _, name := range names {
+			templates[name] = struct{}{}
+		}
+		evt[eventID] = templates
+	}
+	return evt
+}
@@ -0,0 +1,111 @@
+package fir
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// componentEngine renders precompiled components instead of parsing template files
+type componentEngine struct {
+	configs []TemplateConfig
+}
+
+func (e *componentEngine) Parse(config TemplateConfig) (Template, error) {
+	e.configs = append(e.configs, config)
+	return componentTemplate{}, nil
+}
+
+type componentTemplate struct{}
+
+func (componentTemplate) Execute(w io.Writer, data any) error {
+	_, err := fmt.Fprint(w, `<html><head></head><body><div @fir:inc:ok::count="">0</div></body></html>`)
+	return err
+}
+
+func (componentTemplate) ExecuteBlock(w io.Writer, name string, data any) error {
+	if name != "count" {
+		return fmt.Errorf("block %s not found", name)
+	}
+	_, err := fmt.Fprintf(w, "<span>%v</span>", data.(routeData)["count"])
+	return err
+}
+
+func (componentTemplate) Blocks() []string {
+	return []string{"count"}
+}
+
+func (componentTemplate) EventBindings() map[string][]string {
+	return map[string][]string{"inc:ok": {"count"}}
+}
+
+func TestWithTemplateEngine(t *testing.T) {
+	engine := &componentEngine{}
+	c := NewController("test", WithPublicDir("."), WithTemplateEngine(engine))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content("counter"),
+			ErrorContent("counter-error"),
+			OnEvent("inc", func(ctx RouteContext) error { return ctx.KV("count", 1) }),
+		}
+	})
+	if !assert.Len(t, engine.configs, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, "counter", engine.configs[0].Content)
+	assert.Equal(t, "counter-error", engine.configs[1].Content)
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	// the rendered page goes through the event binding pipeline
+	assert.Contains(t, w.Body.String(), `class="fir-inc-ok--count"`)
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_id":"inc"}`))
+	r.Header.Set("X-FIR-MODE", "event")
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `"type":"fir:inc:ok::count"`)
+	assert.Contains(t, w.Body.String(), `"detail":"\u003cspan\u003e1\u003c/span\u003e"`)
+}
+
+// recordingEngine records the configs and delegates parsing to the default engine
+type recordingEngine struct {
+	configs []TemplateConfig
+}
+
+func (e *recordingEngine) Parse(config TemplateConfig) (Template, error) {
+	e.configs = append(e.configs, config)
+	return NewHTMLTemplateEngine().Parse(config)
+}
+
+func TestWrappedHTMLTemplateEngine(t *testing.T) {
+	dir := writeLayouts(t, map[string]string{
+		"base.html": `<html><body>{{template "content" .}}</body></html>`,
+		"app.html":  `{{define "content"}}<main>{{template "app" .}}</main>{{end}}`,
+	})
+	engine := &recordingEngine{}
+	c := NewController("test", WithPublicDir(dir), WithTemplateEngine(engine),
+		WithLayoutParent("app.html", "base.html", "content"))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("app"),
+			Layout("app.html"),
+			Content(`{{define "app"}}page{{end}}`),
+			LayoutContentName("app"),
+		}
+	})
+	if !assert.NotEmpty(t, engine.configs) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]LayoutParent{"app.html": {Layout: "base.html", ContentName: "content"}},
+		engine.configs[0].LayoutParents)
+	assert.Nil(t, engine.configs[0].FS)
+	assert.Contains(t, renderPage(t, c, "/"), `<body><main>page</main></body>`)
+}
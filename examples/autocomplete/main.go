@@ -43,18 +43,14 @@ func index() fir.RouteOptions {
 		return ctx.Data(cities)
 	}
 
-	query := func(ctx fir.RouteContext) error {
-		req := new(queryRequest)
-		if err := ctx.Bind(req); err != nil {
-			return err
-		}
+	query := func(ctx fir.RouteContext, req queryRequest) error {
 		return ctx.Data(filterCities(req.Query))
 	}
 
 	return fir.RouteOptions{
 		fir.Content("app.html"),
 		fir.OnLoad(load),
-		fir.OnEvent("query", query),
+		fir.OnEventOf("query", query),
 	}
 }
 
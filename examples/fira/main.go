@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 
-	"github.com/go-chi/chi/v5"
 	"github.com/livefir/fir"
 	"github.com/livefir/fir/examples/fira/ent"
 	projects "github.com/livefir/fir/examples/fira/routes/projects"
@@ -25,16 +24,8 @@ func main() {
 		log.Fatalf("failed creating schema resources: %v", err)
 	}
 
-	pathParamsOpt := fir.WithPathParamsFunc(
-		func(r *http.Request) fir.PathParams {
-			return fir.PathParams{
-				"id": chi.URLParam(r, "id"),
-			}
-		})
-
-	controller := fir.NewController("app", fir.DevelopmentMode(true), pathParamsOpt)
-	r := chi.NewRouter()
-	r.Handle("/", controller.RouteFunc(projects.Index(db)))
-	r.Handle("/{id}/show", controller.RouteFunc(projects.Show(db)))
-	http.ListenAndServe(":9867", r)
+	controller := fir.NewController("app", fir.DevelopmentMode(true))
+	controller.HandleFunc("/", projects.Index(db))
+	controller.HandleFunc("/{id}/show", projects.Show(db))
+	http.ListenAndServe(":9867", controller)
 }
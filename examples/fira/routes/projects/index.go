@@ -82,8 +82,8 @@ func loadProjects(db *ent.Client) fir.OnEventFunc {
 }
 
 type createReq struct {
-	Title       string `json:"title"`
-	Description string `json:"description"`
+	Title       string `json:"title" validate:"required,min=3"`
+	Description string `json:"description" validate:"max=500"`
 }
 
 func toFieldError(ctx fir.RouteContext, err error) error {
@@ -97,7 +97,7 @@ func toFieldError(ctx fir.RouteContext, err error) error {
 func createProject(db *ent.Client) fir.OnEventFunc {
 	return func(ctx fir.RouteContext) error {
 		var req createReq
-		if err := ctx.Bind(&req); err != nil {
+		if err := ctx.BindAndValidate(&req); err != nil {
 			return err
 		}
 		project, err := db.Project.
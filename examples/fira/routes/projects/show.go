@@ -32,12 +32,12 @@ func load(db *ent.Client) fir.OnEventFunc {
 func updateProject(db *ent.Client) fir.OnEventFunc {
 	type updateReq struct {
 		ID          string `json:"projectID"`
-		Title       string `json:"title"`
-		Description string `json:"description"`
+		Title       string `json:"title" validate:"required,min=3"`
+		Description string `json:"description" validate:"max=500"`
 	}
 	return func(ctx fir.RouteContext) error {
 		var req updateReq
-		if err := ctx.Bind(&req); err != nil {
+		if err := ctx.BindAndValidate(&req); err != nil {
 			return err
 		}
 		uid, err := uuid.Parse(req.ID)
@@ -19,8 +19,8 @@ type App struct {
 	session *ory.Session
 }
 
-func (app *App) sessionMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(writer http.ResponseWriter, request *http.Request) {
+func (app *App) sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
 		log.Printf("handling middleware request\n")
 
 		// set the cookies on the ory client
@@ -42,7 +42,7 @@ func (app *App) sessionMiddleware(next http.HandlerFunc) http.HandlerFunc {
 		app.session = session
 		// continue to the requested page (in our case the Dashboard)
 		next.ServeHTTP(writer, request)
-	}
+	})
 }
 
 var content = `<!DOCTYPE html>
@@ -113,7 +113,8 @@ func main() {
 	}
 
 	controller := fir.NewController("fir-ory-counter", fir.DevelopmentMode(true))
-	http.Handle("/", app.sessionMiddleware(controller.Route(&index{})))
+	controller.Use(app.sessionMiddleware)
+	controller.Handle("/", &index{})
 	log.Println("listening on http://localhost:9867")
-	http.ListenAndServe(":9867", nil)
+	http.ListenAndServe(":9867", controller)
 }
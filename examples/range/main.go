@@ -18,11 +18,7 @@ func index() fir.RouteOptions {
 		fir.OnLoad(func(ctx fir.RouteContext) error {
 			return ctx.Data(map[string]any{"total": 0})
 		}),
-		fir.OnEvent("update", func(ctx fir.RouteContext) error {
-			req := new(countRequest)
-			if err := ctx.Bind(req); err != nil {
-				return err
-			}
+		fir.OnEventOf("update", func(ctx fir.RouteContext, req countRequest) error {
 			count, err := strconv.Atoi(req.Count)
 			if err != nil {
 				return err
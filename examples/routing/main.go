@@ -22,7 +22,7 @@ func about() fir.RouteOptions {
 
 func main() {
 	c := fir.NewController("routing", fir.DevelopmentMode(true))
-	http.Handle("/", c.RouteFunc(home))
-	http.Handle("/about", c.RouteFunc(about))
-	http.ListenAndServe(":9867", nil)
+	c.HandleFunc("/", home)
+	c.HandleFunc("/about", about)
+	http.ListenAndServe(":9867", c)
 }
@@ -39,17 +39,13 @@ type queryRequest struct {
 }
 
 func index() fir.RouteOptions {
-	query := func(ctx fir.RouteContext) error {
-		req := new(queryRequest)
-		if err := ctx.Bind(req); err != nil {
-			return err
-		}
+	query := func(ctx fir.RouteContext, req queryRequest) error {
 		return ctx.Data(filterCities(req.Query))
 	}
 	return fir.RouteOptions{
 		fir.Content("app.html"),
-		fir.OnLoad(query),
-		fir.OnEvent("query", query),
+		fir.OnLoadOf(query),
+		fir.OnEventOf("query", query),
 	}
 }
 
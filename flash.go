@@ -0,0 +1,77 @@
+package fir
+
+import (
+	"encoding/gob"
+	"sync"
+
+	"github.com/livefir/fir/internal/dom"
+)
+
+// flashSessionKey is the session key under which the flash messages of page requests are stored until the next render
+const flashSessionKey = "_fir_flashes_"
+
+// Flash is a one-time message shown to the user after an action, e.g. "Project created".
+type Flash struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+func init() {
+	gob.Register([]Flash{})
+}
+
+// Flash adds a one-time message of the given kind, e.g. "success" or "error", for the user.
+//
+// For page requests, e.g. a form post which redirects on success, the message is stored in the session and
+// available to the next rendered page as {{range .fir.Flashes}}{{.Kind}}: {{.Message}}{{end}}.
+// For events sent by the fir client it is delivered to the client which sent the event as a fir:flash dom event
+// with {kind, message} detail, e.g. @fir:flash.window="show($event.detail.message)".
+func (c RouteContext) Flash(kind, message string) {
+	flash := Flash{Kind: kind, Message: message}
+	if c.pending != nil {
+		eventType := "fir:flash"
+		c.pending.add(dom.Event{Type: &eventType, Detail: flash})
+		return
+	}
+	flashes, _ := SessionValue[[]Flash](c.session, flashSessionKey)
+	c.session.Set(flashSessionKey, append(flashes, flash))
+}
+
+// takeFlashes returns and removes the flash messages stored in the session
+func (c RouteContext) takeFlashes() []Flash {
+	flashes, ok := SessionValue[[]Flash](c.session, flashSessionKey)
+	if !ok {
+		return nil
+	}
+	c.session.Delete(flashSessionKey)
+	c.session.save(c.response)
+	return flashes
+}
+
+// pendingEvents are the dom events for the client which sent an event, written after the event's result.
+type pendingEvents struct {
+	events []dom.Event
+	sync.Mutex
+}
+
+func newPendingEvents() *pendingEvents {
+	return &pendingEvents{}
+}
+
+func (p *pendingEvents) add(event dom.Event) {
+	p.Lock()
+	defer p.Unlock()
+	p.events = append(p.events, event)
+}
+
+// take returns and clears the pending events
+func (p *pendingEvents) take() []dom.Event {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	events := p.events
+	p.events = nil
+	return events
+}
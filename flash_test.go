@@ -0,0 +1,71 @@
+package fir
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func flashController() Controller {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("projects"),
+			Content(`<div @fir:create:ok="">{{range .fir.Flashes}}<p class="{{.Kind}}">{{.Message}}</p>{{end}}</div>`),
+			OnEvent("create", func(ctx RouteContext) error {
+				ctx.Flash("success", "Project created")
+				return nil
+			}),
+		}
+	})
+	return c
+}
+
+func TestFlashSurvivesRedirect(t *testing.T) {
+	c := flashController()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"title": {"fir"}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	get := func(cookies []*http.Cookie) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for _, cookie := range cookies {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		return w
+	}
+
+	cookies := w.Result().Cookies()
+	w = get(cookies)
+	assert.Contains(t, w.Body.String(), `<p class="success">Project created</p>`)
+
+	// the flash is shown once
+	for _, cookie := range w.Result().Cookies() {
+		for i := range cookies {
+			if cookies[i].Name == cookie.Name {
+				cookies[i] = cookie
+			}
+		}
+	}
+	w = get(cookies)
+	assert.NotContains(t, w.Body.String(), "Project created")
+}
+
+func TestFlashEvent(t *testing.T) {
+	c := flashController()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_id":"create"}`))
+	r.Header.Set("X-FIR-MODE", "event")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `{"type":"fir:flash","detail":{"kind":"success","message":"Project created"}}`)
+}
@@ -2,10 +2,17 @@ package fir
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/alecthomas/chroma/formatters/html"
 
@@ -29,6 +36,143 @@ func defaultFuncMap() template.FuncMap {
 	return allFuncs
 }
 
+// WithFuncMap is an option to add template funcs to all the routes of the controller.
+// The funcs set with the FuncMap route option take precedence.
+func WithFuncMap(funcMap template.FuncMap) ControllerOption {
+	return func(o *opt) {
+		o.controllerFuncMap = mergeFuncMaps(o.controllerFuncMap, funcMap)
+	}
+}
+
+// WithCSRF is an option to set the form field rendered by the csrfField template func e.g. WithCSRF("gorilla.csrf.Token", csrf.Token).
+// The token func is called with the request of the rendered page.
+func WithCSRF(fieldName string, token func(r *http.Request) string) ControllerOption {
+	return func(o *opt) {
+		o.csrfFieldName = fieldName
+		o.csrfToken = token
+	}
+}
+
+func mergeFuncMaps(funcMaps ...template.FuncMap) template.FuncMap {
+	merged := make(template.FuncMap)
+	for _, funcMap := range funcMaps {
+		for k, v := range funcMap {
+			merged[k] = v
+		}
+	}
+	return merged
+}
+
+// routeFuncMap returns the template funcs of a route: the default funcs, the framework helpers,
+// the controller's funcs and the route's funcs in increasing order of precedence.
+func (c *controller) routeFuncMap(funcMap template.FuncMap) template.FuncMap {
+	helpers := template.FuncMap{
+		"routeURL":  c.routeURL,
+		"csrfField": csrfField,
+		"asset":     c.asset,
+		"json":      jsonScript,
+	}
+	return mergeFuncMaps(defaultFuncMap(), helpers, c.controllerFuncMap, funcMap)
+}
+
+// routeURL builds the url of the route with the given id from its pattern. The key value pairs fill the path params
+// of the pattern and the remaining pairs are added to the query string.
+// Example: {{routeURL "project" "id" .ID "tab" "settings"}} => /projects/42?tab=settings
+func (c *controller) routeURL(id string, pairs ...any) (string, error) {
+	if len(pairs)%2 != 0 {
+		return "", fmt.Errorf("routeURL %s: odd number of key value pairs", id)
+	}
+	c.RLock()
+	var matcher *routeMatcher
+	for _, m := range c.matchers {
+		if m.route.id == id {
+			matcher = m
+			break
+		}
+	}
+	c.RUnlock()
+	if matcher == nil {
+		return "", fmt.Errorf("routeURL %s: route not found or registered without a pattern", id)
+	}
+
+	values := make(map[string]string)
+	for i := 0; i < len(pairs); i += 2 {
+		key, ok := pairs[i].(string)
+		if !ok {
+			return "", fmt.Errorf("routeURL %s: key %v is not a string", id, pairs[i])
+		}
+		values[key] = fmt.Sprint(pairs[i+1])
+	}
+
+	segments := make([]string, len(matcher.segments))
+	for i, segment := range matcher.segments {
+		name, ok := paramName(segment)
+		if !ok {
+			segments[i] = segment
+			continue
+		}
+		value, ok := values[name]
+		if !ok || value == "" {
+			return "", fmt.Errorf("routeURL %s: missing path param %s", id, name)
+		}
+		segments[i] = url.PathEscape(value)
+		delete(values, name)
+	}
+
+	u := "/" + strings.Join(segments, "/")
+	if len(values) > 0 {
+		query := url.Values{}
+		for k, v := range values {
+			query.Set(k, v)
+		}
+		u += "?" + query.Encode()
+	}
+	return u, nil
+}
+
+// csrfField renders a hidden input with the csrf token of the page set by WithCSRF.
+// Example: <form method="post">{{csrfField .fir}}</form>
+func csrfField(fir *RouteDOMContext) (template.HTML, error) {
+	if fir == nil {
+		// blocks rendered for events without map data don't have the route context
+		return "", nil
+	}
+	if fir.csrfFieldName == "" {
+		return "", fmt.Errorf("csrfField: csrf is not configured, see WithCSRF")
+	}
+	return template.HTML(fmt.Sprintf(`<input type="hidden" name="%s" value="%s">`,
+		template.HTMLEscapeString(fir.csrfFieldName), template.HTMLEscapeString(fir.csrfToken))), nil
+}
+
+// asset returns the url of a file in the public directory fingerprinted with a hash of its content
+// so that it can be cached indefinitely. Example: {{asset "css/app.css"}} => /css/app.css?v=2c26b46b68
+func (c *controller) asset(name string) (string, error) {
+	if !c.disableTemplateCache {
+		if u, ok := c.assets.Load(name); ok {
+			return u.(string), nil
+		}
+	}
+	_, b, err := c.readFile(filepath.Join(c.publicDir, name))
+	if err != nil {
+		return "", fmt.Errorf("asset %s: %w", name, err)
+	}
+	sum := sha256.Sum256(b)
+	u := path.Join("/", filepath.ToSlash(name)) + "?v=" + hex.EncodeToString(sum[:])[:10]
+	c.assets.Store(name, u)
+	return u, nil
+}
+
+// jsonScript encodes the value as json which can be embedded in a script or an alpine.js attribute.
+// Example: <script>const projects = {{json .projects}}</script>
+func jsonScript(v any) (template.JS, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	// json.Marshal escapes <, > and & so the value can't close the script element
+	return template.JS(b), nil
+}
+
 func bytesToMap(data []byte) map[string]any {
 	m := make(map[string]any)
 	err := json.Unmarshal(data, &m)
@@ -0,0 +1,140 @@
+package fir
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func renderPage(t *testing.T, c Controller, path string) string {
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+	body, err := io.ReadAll(w.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, w.Code, string(body))
+	return string(body)
+}
+
+func TestFuncMap(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithFuncMap(template.FuncMap{
+		"greet": func(name string) string { return "hello " + name },
+		"shout": func(s string) string { return strings.ToUpper(s) },
+	}))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("index"),
+			Content(`<p>{{greet "fir"}} {{shout "ok"}} {{upper "sprig"}}</p>`),
+			FuncMap(template.FuncMap{"shout": func(s string) string { return s + "!" }}),
+		}
+	})
+	// the route's funcs take precedence over the controller's funcs
+	assert.Contains(t, renderPage(t, c, "/"), "<p>hello fir ok! SPRIG</p>")
+}
+
+func TestRouteURL(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("index"),
+			Content(`<a href="{{routeURL "project" "org" "acme" "id" 42 "tab" "a b"}}">project</a>`),
+		}
+	})
+	c.Group("/orgs").HandleFunc("/{org}/projects/{id}", func() RouteOptions {
+		return RouteOptions{ID("project"), Content("project")}
+	})
+	assert.Contains(t, renderPage(t, c, "/"), `<a href="/orgs/acme/projects/42?tab=a+b">`)
+}
+
+func TestRouteURLErrors(t *testing.T) {
+	c := NewController("test", WithPublicDir(".")).(*controller)
+	c.HandleFunc("/projects/{id}", func() RouteOptions {
+		return RouteOptions{ID("project"), Content("project")}
+	})
+
+	u, err := c.routeURL("project", "id", "a/b")
+	assert.NoError(t, err)
+	assert.Equal(t, "/projects/a%2Fb", u)
+
+	_, err = c.routeURL("project")
+	assert.ErrorContains(t, err, "missing path param id")
+	_, err = c.routeURL("project", "id")
+	assert.ErrorContains(t, err, "odd number")
+	_, err = c.routeURL("missing")
+	assert.ErrorContains(t, err, "route not found")
+}
+
+func TestCSRFField(t *testing.T) {
+	token := func(r *http.Request) string { return "token-" + r.URL.Path }
+	c := NewController("test", WithPublicDir("."), WithCSRF("csrf_token", token))
+	c.HandleFunc("/form", func() RouteOptions {
+		return RouteOptions{
+			ID("form"),
+			Content(`<form method="post">{{csrfField .fir}}</form>`),
+		}
+	})
+	assert.Contains(t, renderPage(t, c, "/form"), `<input type="hidden" name="csrf_token" value="token-/form"/>`)
+
+	_, err := csrfField(&RouteDOMContext{})
+	assert.ErrorContains(t, err, "WithCSRF")
+}
+
+func TestCSRFFieldInEventBlock(t *testing.T) {
+	token := func(r *http.Request) string { return "token-" + r.URL.Query().Get("client") }
+	c := NewController("test", WithPublicDir("."), WithCSRF("csrf_token", token), WithBlockCache(10, 0))
+	c.HandleFunc("/form", func() RouteOptions {
+		return RouteOptions{
+			ID("form"),
+			Content(`<div @fir:save:ok::form="">{{block "form" .}}<form>{{csrfField .fir}}{{.name}}</form>{{end}}</div>`),
+			OnEvent("save", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	id := "save"
+	render := func(client string) string {
+		ctx := RouteContext{
+			request: httptest.NewRequest(http.MethodGet, "/form?client="+client, nil),
+			route:   c.(*controller).routes["form"],
+		}
+		events := renderDOMEvents(ctx, pubsub.Event{ID: &id, State: eventstate.OK, Detail: routeData{"name": "x"}})
+		if !assert.Len(t, events, 1) {
+			return ""
+		}
+		return events[0].Detail.(string)
+	}
+	// the block is rendered with each client's token even if cached
+	assert.Equal(t, `<form><input type=hidden name=csrf_token value=token-a>x</form>`, render("a"))
+	assert.Equal(t, `<form><input type=hidden name=csrf_token value=token-b>x</form>`, render("b"))
+}
+
+func TestAssetAndJSON(t *testing.T) {
+	dir := t.TempDir()
+	css := []byte("body { color: red; }")
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "css"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "css", "app.css"), css, 0o644))
+	sum := sha256.Sum256(css)
+
+	c := NewController("test", WithPublicDir(dir))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("index"),
+			Content(`<link href="{{asset "css/app.css"}}"/><script>const data = {{json .data}};</script>`),
+			OnLoad(func(ctx RouteContext) error {
+				return ctx.KV("data", map[string]string{"title": "</script><b>"})
+			}),
+		}
+	})
+	body := renderPage(t, c, "/")
+	assert.Contains(t, body, `<link href="/css/app.css?v=`+hex.EncodeToString(sum[:])[:10]+`"/>`)
+	// the json can't close the script element
+	assert.Contains(t, body, `const data = {"title":"\u003c/script\u003e\u003cb\u003e"};`)
+}
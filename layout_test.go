@@ -0,0 +1,78 @@
+package fir
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeLayouts(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestNestedLayouts(t *testing.T) {
+	dir := writeLayouts(t, map[string]string{
+		"base.html":     `<html><head><title>base</title></head><body>{{template "content" .}}</body></html>`,
+		"app.html":      `{{define "content"}}<nav>sidebar</nav><main>{{template "app" .}}</main>{{end}}`,
+		"settings.html": `{{define "app"}}<h1>settings</h1><section>{{template "settings" .}}</section>{{end}}`,
+	})
+	c := NewController("test", WithPublicDir(dir),
+		WithLayoutParent("app.html", "base.html", "content"),
+		WithLayoutParent("settings.html", "app.html", "app"))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("profile"),
+			Layout("settings.html"),
+			Content(`{{define "settings"}}<p>profile</p>{{end}}`),
+			LayoutContentName("settings"),
+			ErrorContent(`{{define "settings"}}<p>error: {{.fir.Error "onload"}}</p>{{end}}`),
+			ErrorLayout("settings.html"),
+			ErrorLayoutContentName("settings"),
+		}
+	})
+	assert.Contains(t, renderPage(t, c, "/"),
+		`<body><nav>sidebar</nav><main><h1>settings</h1><section><p>profile</p></section></main></body>`)
+
+	// the error page is nested in the same layouts
+	var buf bytes.Buffer
+	err := c.(*controller).routes["profile"].errorTemplate.Execute(&buf,
+		routeData{"fir": &RouteDOMContext{errors: map[string]any{"onload": "failed"}}})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), `<main><h1>settings</h1><section><p>error: failed</p></section></main>`)
+}
+
+func TestLayoutParentErrors(t *testing.T) {
+	dir := writeLayouts(t, map[string]string{
+		"base.html": `<html><body>{{template "content" .}}</body></html>`,
+		"app.html":  `{{define "main"}}app{{end}}`,
+		"a.html":    `{{define "content"}}a{{end}}`,
+		"b.html":    `{{define "content"}}b{{end}}`,
+	})
+	c := NewController("test", WithPublicDir(dir), WithTemplateDiagnostics(),
+		WithLayoutParent("app.html", "base.html", "content"),
+		WithLayoutParent("a.html", "b.html", "content"),
+		WithLayoutParent("b.html", "a.html", "content"))
+	c.RouteFunc(func() RouteOptions {
+		return RouteOptions{ID("missing"), Layout("app.html"), Content("page")}
+	})
+	c.RouteFunc(func() RouteOptions {
+		return RouteOptions{ID("cycle"), Layout("a.html"), Content("page")}
+	})
+
+	diagnostics := c.Validate()
+	if !assert.Len(t, diagnostics, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, "cycle", diagnostics[0].RouteID)
+	assert.Contains(t, diagnostics[0].Reason, "cycle in layout parents")
+	assert.Equal(t, "missing", diagnostics[1].RouteID)
+	assert.Contains(t, diagnostics[1].Reason, "layout app.html")
+	assert.Contains(t, diagnostics[1].Reason, "expects a template named content")
+}
@@ -0,0 +1,123 @@
+package fir
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"k8s.io/klog/v2"
+)
+
+// RouteInfo describes a route registered with the controller. It can be marshalled to json
+// and is served as part of the route manifest when WithRouteManifest is set.
+type RouteInfo struct {
+	// ID is the route's unique identifier
+	ID string `json:"id"`
+	// Pattern is the url pattern the route is mounted on. It is empty for routes registered with Route or RouteFunc.
+	Pattern string `json:"pattern,omitempty"`
+	// Content is the route's content file, directory or html string
+	Content string `json:"content,omitempty"`
+	// Layout is the route's layout file or html string
+	Layout string `json:"layout,omitempty"`
+	// LayoutContentName is the name of the template in the layout which renders the content
+	LayoutContentName string `json:"layout_content_name,omitempty"`
+	// ErrorContent is the route's error content file, directory or html string
+	ErrorContent string `json:"error_content,omitempty"`
+	// ErrorLayout is the route's error layout file or html string
+	ErrorLayout string `json:"error_layout,omitempty"`
+	// Partials are the route's partial files or directories
+	Partials []string `json:"partials,omitempty"`
+	// Events are the event ids registered with OnEvent
+	Events []string `json:"events"`
+	// EventTemplates maps an event binding i.e. event:state to the templates rendered for it.
+	// "-" means the binding doesn't declare a template.
+	EventTemplates map[string][]string `json:"event_templates"`
+	// Templates are all the templates parsed for the route
+	Templates []string `json:"templates"`
+}
+
+// WithRouteManifest is an option to serve a json manifest of the controller's routes at the given url path.
+// The manifest lists the template files and event ids of the routes. It passes through the middlewares added
+// with Controller.Use, but not those of route groups, so protect it there if it must not be public. See Controller.Routes.
+func WithRouteManifest(path string) ControllerOption {
+	return func(o *opt) {
+		o.routeManifestPath = path
+	}
+}
+
+// Routes returns the metadata of the routes registered with the controller sorted by route id.
+func (c *controller) Routes() []RouteInfo {
+	c.RLock()
+	patterns := make(map[*route]string)
+	for _, m := range c.matchers {
+		patterns[m.route] = m.pattern
+	}
+	routes := make([]*route, 0, len(c.routes))
+	for _, rt := range c.routes {
+		routes = append(routes, rt)
+	}
+	c.RUnlock()
+
+	infos := make([]RouteInfo, 0, len(routes))
+	for _, rt := range routes {
+		info := rt.info()
+		info.Pattern = patterns[rt]
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos
+}
+
+func (c *controller) serveRouteManifest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := json.MarshalIndent(c.Routes(), "", "  ")
+	if err != nil {
+		klog.Errorf("[serveRouteManifest] error marshaling routes: %v\n", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (rt *route) info() RouteInfo {
+	rt.RLock()
+	defer rt.RUnlock()
+
+	events := []string{}
+	for eventID := range rt.onEvents {
+		events = append(events, eventID)
+	}
+	sort.Strings(events)
+
+	eventTemplates := make(map[string][]string)
+	for eventID, templates := range rt.eventTemplates {
+		var names []string
+		for name := range templates {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		eventTemplates[eventID] = names
+	}
+
+	templates := append([]string{}, rt.allTemplates...)
+	sort.Strings(templates)
+
+	return RouteInfo{
+		ID:                rt.id,
+		Content:           rt.content,
+		Layout:            rt.layout,
+		LayoutContentName: rt.layoutContentName,
+		ErrorContent:      rt.errorContent,
+		ErrorLayout:       rt.errorLayout,
+		Partials:          rt.partials,
+		Events:            events,
+		EventTemplates:    eventTemplates,
+		Templates:         templates,
+	}
+}
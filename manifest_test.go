@@ -0,0 +1,64 @@
+package fir
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestControllerRoutes(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithRouteManifest("/_fir/routes"))
+	c.HandleFunc("/projects/{id}", func() RouteOptions {
+		return RouteOptions{
+			ID("project"),
+			Content(`<div @fir:create:ok::item="" @fir:create:error=""></div>{{define "item"}}<p></p>{{end}}`),
+			OnEvent("create", func(ctx RouteContext) error { return nil }),
+			OnEvent("delete", func(ctx RouteContext) error { return nil }),
+		}
+	})
+
+	routes := c.Routes()
+	assert.Len(t, routes, 1)
+	info := routes[0]
+	assert.Equal(t, "project", info.ID)
+	assert.Equal(t, "/projects/{id}", info.Pattern)
+	assert.Equal(t, []string{"create", "delete"}, info.Events)
+	assert.Equal(t, map[string][]string{
+		"create:ok":    {"item"},
+		"create:error": {"-"},
+	}, info.EventTemplates)
+	assert.Contains(t, info.Templates, "item")
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_fir/routes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var manifest []RouteInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &manifest))
+	assert.Equal(t, routes, manifest)
+
+	// a controller without routes serves an empty list
+	empty := NewController("test", WithPublicDir("."), WithRouteManifest("/_fir/routes"))
+	w = httptest.NewRecorder()
+	empty.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_fir/routes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]", w.Body.String())
+}
+
+func TestRouteManifestMiddlewares(t *testing.T) {
+	var reject atomic.Bool
+	reject.Store(true)
+	c := NewController("test", WithPublicDir("."), WithRouteManifest("/_fir/routes"))
+	c.Use(rejectWhen(&reject))
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_fir/routes", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	reject.Store(false)
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/_fir/routes", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,119 @@
+package fir
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Router registers routes and the middlewares wrapping them. A middleware wraps the page loads, form posts, events
+// and websocket upgrades of a route. Events received over an open websocket connection are passed through the
+// middleware chain too, with the upgrade request, so that a middleware can reject them.
+type Router interface {
+	// Use appends middlewares to the router's middleware chain.
+	Use(middlewares ...func(http.Handler) http.Handler)
+	// Group creates a router whose routes are mounted under the prefix and wrapped by the given middlewares
+	// in addition to the middlewares of the parent router.
+	Group(prefix string, middlewares ...func(http.Handler) http.Handler) Router
+	// Handle registers the route for the given pattern.
+	Handle(pattern string, route Route)
+	// HandleFunc registers the route func for the given pattern.
+	HandleFunc(pattern string, routeFunc RouteFunc)
+}
+
+type routeGroup struct {
+	cntrl       *controller
+	parent      *routeGroup
+	prefix      string
+	middlewares []func(http.Handler) http.Handler
+	sync.RWMutex
+}
+
+func (g *routeGroup) Use(middlewares ...func(http.Handler) http.Handler) {
+	g.Lock()
+	defer g.Unlock()
+	g.middlewares = append(g.middlewares, middlewares...)
+}
+
+func (g *routeGroup) Group(prefix string, middlewares ...func(http.Handler) http.Handler) Router {
+	return &routeGroup{
+		cntrl:       g.cntrl,
+		parent:      g,
+		prefix:      joinPath(g.prefix, prefix),
+		middlewares: middlewares,
+	}
+}
+
+func (g *routeGroup) Handle(pattern string, route Route) {
+	g.cntrl.handle(g, joinPath(g.prefix, pattern), route.Options())
+}
+
+func (g *routeGroup) HandleFunc(pattern string, routeFunc RouteFunc) {
+	g.cntrl.handle(g, joinPath(g.prefix, pattern), routeFunc())
+}
+
+// chain returns the middlewares of the group and its parents, outermost first.
+func (g *routeGroup) chain() []func(http.Handler) http.Handler {
+	if g == nil {
+		return nil
+	}
+	g.RLock()
+	defer g.RUnlock()
+	return append(g.parent.chain(), g.middlewares...)
+}
+
+// wrap wraps the handler with the group's middleware chain. The chain is resolved per request
+// so that middlewares added with Use after a route is registered still apply.
+func (g *routeGroup) wrap(h http.Handler) http.Handler {
+	middlewares := g.chain()
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+func joinPath(prefix, pattern string) string {
+	prefix = strings.TrimSuffix(prefix, "/")
+	if pattern == "" {
+		pattern = "/"
+	}
+	if !strings.HasPrefix(pattern, "/") {
+		pattern = "/" + pattern
+	}
+	if prefix == "" {
+		return pattern
+	}
+	if pattern == "/" {
+		return prefix
+	}
+	return prefix + pattern
+}
+
+// discardResponseWriter is the http.ResponseWriter used where there is no http response to write to, e.g. the middleware
+// chain for events received over a websocket connection. Anything written is discarded and only the status code is
+// recorded to detect whether a middleware rejected the event.
+type discardResponseWriter struct {
+	header http.Header
+	status int
+}
+
+func newDiscardResponseWriter() *discardResponseWriter {
+	return &discardResponseWriter{header: make(http.Header)}
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
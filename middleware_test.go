@@ -0,0 +1,120 @@
+package fir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// rejectWhen returns a middleware which responds with 403 while reject is set
+func rejectWhen(reject *atomic.Bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if reject.Load() {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func TestMiddlewareRejects(t *testing.T) {
+	var reject atomic.Bool
+	var calls int32
+	c := NewController("test", WithPublicDir("."))
+	c.Use(rejectWhen(&reject))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content(`<div @fir:inc:ok::count="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			OnEvent("inc", func(ctx RouteContext) error {
+				return ctx.KV("count", atomic.AddInt32(&calls, 1))
+			}),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	reject.Store(true)
+	resp, err := http.Get(srv.URL)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "page")
+
+	resp, err = http.PostForm(srv.URL+"?event=inc", url.Values{"name": {"a"}})
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "form post")
+
+	r, _ := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(`{"event_id":"inc"}`))
+	r.Header.Set("X-FIR-MODE", "event")
+	resp, err = http.DefaultClient.Do(r)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "event post")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	reject.Store(false)
+	sessionID := getPageSessionID(t, srv.URL)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer conn.Close()
+	for !c.(*controller).pubsub.HasSubscribers(context.Background(), "anonymous:counter") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	assert.NoError(t, conn.WriteJSON(map[string]any{"event_id": "inc", "session_id": sessionID}))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(message), `\u003cspan\u003e1\u003c/span\u003e`)
+
+	// events over an open connection are passed through the middlewares with the upgrade request
+	reject.Store(true)
+	assert.NoError(t, conn.WriteJSON(map[string]any{"event_id": "inc", "session_id": sessionID}))
+	conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+	_, _, err = conn.ReadMessage()
+	assert.Error(t, err, "websocket event")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGroupMiddlewareScope(t *testing.T) {
+	var reject atomic.Bool
+	reject.Store(true)
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{ID("home"), Content("home")}
+	})
+	admin := c.Group("/admin", rejectWhen(&reject))
+	admin.HandleFunc("/users", func() RouteOptions {
+		return RouteOptions{ID("users"), Content("users")}
+	})
+	public := c.Group("/public")
+	public.HandleFunc("/about", func() RouteOptions {
+		return RouteOptions{ID("about"), Content("about")}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	for path, status := range map[string]int{
+		"/":             http.StatusOK,
+		"/admin/users":  http.StatusForbidden,
+		"/public/about": http.StatusOK,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		assert.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, status, resp.StatusCode, path)
+	}
+}
@@ -0,0 +1,76 @@
+package fir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func navigationController() Controller {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("projects"),
+			Content(`<div @fir:create:ok="" @fir:page:ok="">projects</div>`),
+			OnEvent("create", func(ctx RouteContext) error {
+				return ctx.Redirect("/projects/1", http.StatusFound)
+			}),
+			OnEvent("page", func(ctx RouteContext) error {
+				ctx.PushURL("/?page=2")
+				ctx.ReplaceURL("/?page=3")
+				return nil
+			}),
+		}
+	})
+	return c
+}
+
+func TestRedirectFormPost(t *testing.T) {
+	c := navigationController()
+	r := httptest.NewRequest(http.MethodPost, "/?event=create", nil)
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/projects/1", w.Header().Get("Location"))
+}
+
+func TestNavigationEvents(t *testing.T) {
+	c := navigationController()
+
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_id":"page"}`))
+	r.Header.Set("X-FIR-MODE", "event")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Contains(t, w.Body.String(), `{"type":"fir:push-url","detail":{"url":"/?page=2"}},{"type":"fir:replace-url","detail":{"url":"/?page=3"}}`)
+
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	sessionID := getPageSessionID(t, srv.URL)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	for !c.(*controller).pubsub.HasSubscribers(context.Background(), "anonymous:projects") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = conn.WriteJSON(map[string]any{"event_id": "create", "session_id": sessionID})
+	assert.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, message, err := conn.ReadMessage()
+		if !assert.NoError(t, err) {
+			return
+		}
+		if strings.Contains(string(message), "fir:redirect") {
+			assert.Contains(t, string(message), `{"type":"fir:redirect","detail":{"url":"/projects/1"}}`)
+			return
+		}
+	}
+}
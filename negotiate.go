@@ -0,0 +1,207 @@
+package fir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	firErrors "github.com/livefir/fir/internal/errors"
+	"k8s.io/klog/v2"
+)
+
+// wantsJSON returns true for requests which prefer a json response to rendered html, e.g. from a mobile app.
+// The media types of the Accept header are ranked by their q-values and html wins a tie unless it is only accepted
+// through a wildcard e.g. "application/json, */*". Requests made by the fir client(X-FIR-MODE) are excluded.
+func wantsJSON(r *http.Request) bool {
+	if r.Header.Get("X-FIR-MODE") != "" {
+		return false
+	}
+	var jsonQ, htmlQ, wildcardQ float64
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		var best *float64
+		switch mediaType {
+		case "application/json":
+			best = &jsonQ
+		case "text/html", "application/xhtml+xml":
+			best = &htmlQ
+		case "text/*", "*/*":
+			best = &wildcardQ
+		default:
+			continue
+		}
+		if q > *best {
+			*best = q
+		}
+	}
+	if htmlQ == 0 {
+		return jsonQ > 0 && jsonQ >= wildcardQ
+	}
+	return jsonQ > htmlQ
+}
+
+// onJSON serves the route's handlers as a json api. A GET request calls the onLoad handler. A POST request calls the
+// onEvent handler selected by the ?event=myaction query param, or the only one, with the json or form request body as its params.
+//
+// The data set by ctx.Data or ctx.KV is returned as a json object. Errors are returned as {"error": "message"} with the status code
+// of ctx.Status or 500, and field errors as {"errors": {"field": "message"}} with 422 Unprocessable Entity.
+// The changes made by a successful event are published to the route's subscribers like events sent by the fir client.
+func onJSON(w http.ResponseWriter, r *http.Request, rt *route) {
+	rw := &jsonResponseWriter{ResponseWriter: w}
+	switch r.Method {
+	case http.MethodGet:
+		ctx := RouteContext{
+			event:    Event{ID: rt.id},
+			request:  r,
+			response: rw,
+			route:    rt,
+			isOnLoad: true,
+			session:  rt.cntrl.session(rw, r),
+		}
+		writeJSONResult(rw, ctx.call(rt.onLoad))
+	case http.MethodPost:
+		eventID, err := rt.formAction(r)
+		if err != nil {
+			writeJSONError(rw, http.StatusBadRequest, err)
+			return
+		}
+		onEventFunc, ok := rt.onEvents[strings.ToLower(eventID)]
+		if !ok {
+			writeJSONError(rw, http.StatusBadRequest, errors.New("event id is not registered"))
+			return
+		}
+		ctx := RouteContext{
+			event:    Event{ID: eventID},
+			request:  r,
+			response: rw,
+			route:    rt,
+			session:  rt.cntrl.session(rw, r),
+			targets:  newPublishTargets(),
+		}
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if mediaType == "application/json" {
+			params, err := io.ReadAll(http.MaxBytesReader(rw, r.Body, rt.maxUploadSize))
+			if err != nil {
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeJSONError(rw, http.StatusRequestEntityTooLarge,
+						fmt.Errorf("request body is larger than %d bytes", rt.maxUploadSize))
+					return
+				}
+				writeJSONError(rw, http.StatusBadRequest, err)
+				return
+			}
+			if len(params) > 0 {
+				ctx.event.Params = params
+			}
+		} else {
+			if status, err := parseForm(rw, r, rt.maxUploadSize); err != nil {
+				writeJSONError(rw, status, err)
+				return
+			}
+			params, err := json.Marshal(r.PostForm)
+			if err != nil {
+				writeJSONError(rw, http.StatusBadRequest, err)
+				return
+			}
+			ctx.event.Params = params
+			ctx.event.IsForm = true
+			ctx.urlValues = r.PostForm
+		}
+
+		err = ctx.call(onEventFunc)
+		if err == nil || isRouteData(err) {
+			publishJSONEvent(ctx, err)
+		}
+		writeJSONResult(rw, err)
+	default:
+		writeJSONError(rw, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	}
+}
+
+func isRouteData(err error) bool {
+	_, ok := err.(*routeData)
+	return ok
+}
+
+// publishJSONEvent publishes the result of a successful event to the route's subscribers
+func publishJSONEvent(ctx RouteContext, result error) {
+	if err := publishEvents(ctx.request.Context(), ctx)(resultEvent(ctx, result)); err != nil {
+		klog.Errorf("[onJSON] error publishing event %s: %v\n", ctx.event.ID, err)
+	}
+}
+
+// writeJSONResult writes the result of a handler unless the handler already wrote the response, e.g. ctx.Redirect.
+func writeJSONResult(w *jsonResponseWriter, result error) {
+	if w.written {
+		return
+	}
+	if result == nil {
+		writeJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
+	switch errVal := result.(type) {
+	case *routeData:
+		writeJSON(w, http.StatusOK, *errVal)
+	case *firErrors.Status:
+		writeJSONError(w, errVal.Code, errVal.Err)
+	case firErrors.Status:
+		writeJSONError(w, errVal.Code, errVal.Err)
+	case *firErrors.Fields:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": errVal.Map()})
+	case firErrors.Fields:
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{"errors": errVal.Map()})
+	default:
+		writeJSONError(w, http.StatusInternalServerError, result)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	message := http.StatusText(status)
+	if err != nil {
+		message = firErrors.User(err).Error()
+	}
+	writeJSON(w, status, map[string]any{"error": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		klog.Errorf("[onJSON] error marshaling response %+v: %v\n", v, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// jsonResponseWriter records whether the handler wrote the response
+type jsonResponseWriter struct {
+	http.ResponseWriter
+	written bool
+}
+
+func (w *jsonResponseWriter) WriteHeader(status int) {
+	w.written = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *jsonResponseWriter) Write(b []byte) (int, error) {
+	w.written = true
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,114 @@
+package fir
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONResponses(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("todos"),
+			Content(`<div>{{.title}}</div>`),
+			OnLoad(func(ctx RouteContext) error {
+				if ctx.Request().URL.Query().Get("missing") != "" {
+					return ctx.Status(http.StatusNotFound, errors.New("todo not found"))
+				}
+				return ctx.KV("title", "groceries")
+			}),
+			OnEventOf("create", func(ctx RouteContext, req struct {
+				Title string `json:"title" validate:"required"`
+			}) error {
+				return ctx.KV("title", req.Title)
+			}),
+			OnEvent("fail", func(ctx RouteContext) error {
+				return errors.New("boom")
+			}),
+		}
+	})
+
+	serve := func(method, target, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Accept", "application/json")
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		return w
+	}
+
+	w := serve(http.MethodGet, "/", "", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"title":"groceries"}`, w.Body.String())
+
+	w = serve(http.MethodGet, "/?missing=1", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"error":"todo not found"}`, w.Body.String())
+
+	w = serve(http.MethodPost, "/?event=create", "application/json", `{"title":"milk"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"title":"milk"}`, w.Body.String())
+
+	w = serve(http.MethodPost, "/?event=create", "application/x-www-form-urlencoded", url.Values{"title": {"eggs"}}.Encode())
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"title":"eggs"}`, w.Body.String())
+
+	w = serve(http.MethodPost, "/?event=create", "application/json", `{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"errors":{"title":"title is required"}}`, w.Body.String())
+
+	w = serve(http.MethodPost, "/?event=fail", "application/json", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"boom"}`, w.Body.String())
+
+	// html is still rendered for browsers
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("Accept", "text/html,application/xhtml+xml")
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, r)
+	assert.Contains(t, rec.Body.String(), "<div>groceries</div>")
+}
+
+func TestWantsJSON(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"application/json":                                                true,
+		"application/json, text/plain, */*":                               true,
+		"text/html, application/json;q=0.1":                               false,
+		"application/json;q=0.9, text/html;q=0.5":                         true,
+		"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8": false,
+		"*/*;q=1, application/json;q=0.5":                                 false,
+		"application/json;q=0":                                            false,
+		"":                                                                false,
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", accept)
+		assert.Equal(t, want, wantsJSON(r), accept)
+	}
+}
+
+func TestJSONBodyLimit(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithMaxUploadSize(16))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("todos"),
+			Content("todos"),
+			OnEvent("create", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	r := httptest.NewRequest(http.MethodPost, "/?event=create", strings.NewReader(`{"title":"`+strings.Repeat("a", 32)+`"}`))
+	r.Header.Set("Accept", "application/json")
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.JSONEq(t, `{"error":"request body is larger than 16 bytes"}`, w.Body.String())
+}
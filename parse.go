@@ -39,6 +39,30 @@ func layoutEmptyContentSet(opt routeOpt, content, layoutContentName string) (*te
 }
 
 func layoutSetContentEmpty(opt routeOpt, layout string) (*template.Template, eventTemplates, error) {
+	chain, err := layoutChain(opt, layout)
+	if err != nil {
+		return nil, nil, err
+	}
+	layoutTemplate, evt, err := parseLayout(opt, chain[0].layout)
+	if err != nil {
+		return nil, evt, err
+	}
+	// nest the layouts inside their parents starting from the outermost one
+	for _, link := range chain[1:] {
+		var currEvt eventTemplates
+		layoutTemplate, currEvt, err = parseChildLayout(opt, layoutTemplate, link.layout)
+		if err != nil {
+			return nil, nil, err
+		}
+		evt = deepMergeEventTemplates(evt, currEvt)
+		if err := checkPageContent(layoutTemplate, link.contentName); err != nil {
+			return nil, nil, fmt.Errorf("layout %s: %w", link.layout, err)
+		}
+	}
+	return layoutTemplate, evt, nil
+}
+
+func parseLayout(opt routeOpt, layout string) (*template.Template, eventTemplates, error) {
 	pageLayoutPath := filepath.Join(opt.publicDir, layout)
 	evt := make(eventTemplates)
 	// is layout html content or a file/directory
@@ -58,6 +82,54 @@ func layoutSetContentEmpty(opt routeOpt, layout string) (*template.Template, eve
 	return parseFiles(template.Must(layoutTemplate.Clone()), opt.readFile, commonFiles...)
 }
 
+// parseChildLayout adds a layout nested in a parent layout to the parent's template set.
+func parseChildLayout(opt routeOpt, parent *template.Template, layout string) (*template.Template, eventTemplates, error) {
+	pageLayoutPath := filepath.Join(opt.publicDir, layout)
+	if isFileOrString(pageLayoutPath, opt) {
+		return parseString(parent, layout)
+	}
+	if isDir(pageLayoutPath, opt) {
+		return nil, nil, fmt.Errorf("layout %s is a directory but must be a file", pageLayoutPath)
+	}
+	// the file name is the name of the layout's template in the set
+	if parent.Lookup(filepath.Base(pageLayoutPath)) != nil {
+		return nil, nil, fmt.Errorf("layout %s: a template named %s is already defined by a parent layout or partial",
+			layout, filepath.Base(pageLayoutPath))
+	}
+	return parseFiles(parent, opt.readFile, pageLayoutPath)
+}
+
+// LayoutParent is the parent of a layout set with WithLayoutParent. The layout defines the template named ContentName
+// which is rendered by the Layout.
+type LayoutParent struct {
+	Layout      string
+	ContentName string
+}
+
+// layoutLink is a layout in a chain of nested layouts and the name of the template it defines for its parent.
+type layoutLink struct {
+	layout      string
+	contentName string
+}
+
+// layoutChain returns the layout and its ancestors starting from the outermost layout.
+func layoutChain(opt routeOpt, layout string) ([]layoutLink, error) {
+	chain := []layoutLink{{layout: layout}}
+	seen := map[string]bool{filepath.Clean(layout): true}
+	for {
+		parent, ok := opt.layoutParents[filepath.Clean(chain[0].layout)]
+		if !ok {
+			return chain, nil
+		}
+		if seen[filepath.Clean(parent.Layout)] {
+			return nil, fmt.Errorf("layout %s: cycle in layout parents", layout)
+		}
+		seen[filepath.Clean(parent.Layout)] = true
+		chain[0].contentName = parent.ContentName
+		chain = append([]layoutLink{{layout: parent.Layout}}, chain...)
+	}
+}
+
 func layoutSetContentSet(opt routeOpt, content, layout, layoutContentName string) (*template.Template, eventTemplates, error) {
 	layoutTemplate, evt, err := layoutSetContentEmpty(opt, layout)
 	if err != nil {
@@ -76,7 +148,7 @@ func layoutSetContentSet(opt routeOpt, content, layout, layoutContentName string
 	if isFileOrString(pageContentPath, opt) {
 		pageTemplate, currEvt, err := parseString(layoutTemplate, content)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
 		}
 		evt = deepMergeEventTemplates(evt, currEvt)
 		if err := checkPageContent(pageTemplate, layoutContentName); err != nil {
@@ -87,7 +159,7 @@ func layoutSetContentSet(opt routeOpt, content, layout, layoutContentName string
 		pageFiles := getPartials(opt, []string{pageContentPath})
 		pageTemplate, currEvt, err := parseFiles(layoutTemplate.Funcs(opt.funcMap), opt.readFile, pageFiles...)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
 		}
 		evt = deepMergeEventTemplates(evt, currEvt)
 		if err := checkPageContent(pageTemplate, layoutContentName); err != nil {
@@ -135,36 +207,12 @@ func parseTemplate(opt routeOpt) (*template.Template, eventTemplates, error) {
 	return layoutSetContentSet(opt, opt.content, opt.layout, opt.layoutContentName)
 }
 
-// creates a html/template for the route errors
-func parseErrorTemplate(opt routeOpt) (*template.Template, eventTemplates, error) {
-	if opt.errorLayout == "" {
-		opt.errorLayout = opt.layout
-		opt.errorLayoutContentName = opt.layoutContentName
-	}
-	// if both layout and content is empty show a default page.
-	if opt.errorLayout == "" && opt.errorContent == "" {
-		return template.Must(template.New("").
-			Parse(`<div style="text-align:center"> This is a default page. </div>`)), nil, nil
-	}
-
-	// if layout is set and content is empty
-	if opt.errorLayout != "" && opt.errorContent == "" {
-		return layoutSetContentEmpty(opt, opt.errorLayout)
-	}
-
-	// if layout is empty and content is set
-	if opt.errorLayout == "" && opt.errorContent != "" {
-		return layoutEmptyContentSet(opt, opt.errorContent, opt.errorLayoutContentName)
-	}
-
-	// both layout and content are set
-	return layoutSetContentSet(opt, opt.errorContent, opt.errorLayout, opt.errorLayoutContentName)
-}
-
 type fileInfo struct {
 	name           string
 	content        []byte
 	eventTemplates eventTemplates
+	bindings       []eventBinding
+	diagnostics    []Diagnostic
 	err            error
 }
 
@@ -172,6 +220,7 @@ var templateNameRegex = regexp.MustCompile(`^[ A-Za-z0-9\-:]*$`)
 
 func parseString(t *template.Template, content string) (*template.Template, eventTemplates, error) {
 	fi := query(fileInfo{content: []byte(content)})
+	logDiagnostics(fi.diagnostics)
 	t, err := t.Parse(string(fi.content))
 	return t, fi.eventTemplates, err
 }
@@ -196,6 +245,7 @@ func parseFiles(t *template.Template, readFile func(string) (string, []byte, err
 	fileInfos := resultPool.Wait()
 	for _, fi := range fileInfos {
 		evt = deepMergeEventTemplates(evt, fi.eventTemplates)
+		logDiagnostics(fi.diagnostics)
 		if fi.err != nil {
 			return t, evt, fi.err
 		}
@@ -226,18 +276,32 @@ func parseFiles(t *template.Template, readFile func(string) (string, []byte, err
 }
 
 func eventFormatError(eventns string) string {
-	return fmt.Sprintf(`
-	error: invalid event namespace: %s. must be of either of the two formats =>
-	1. @fir:<event>:<ok|error>::<block-name|optional>
-	2. @fir:<event>:<pending|done>`, eventns)
+	return fmt.Sprintf("invalid event namespace %s: must be either @fir:<event>:<ok|error>::<block-name|optional> or @fir:<event>:<pending|done>", eventns)
+}
+
+// meta is a <meta name="name" content="content"> tag added to the head of a rendered page
+type meta struct {
+	name    string
+	content string
 }
 
-func transform(content []byte) []byte {
+// transform expands the event bindings of the html into attributes and classes and adds the meta tags
+// to the head of the html document
+func transform(content []byte, metas ...meta) []byte {
 	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
 	if err != nil {
 		panic(err)
 	}
 
+	if len(metas) > 0 {
+		var tags strings.Builder
+		for _, m := range metas {
+			fmt.Fprintf(&tags, `<meta name="%s" content="%s"/>`,
+				template.HTMLEscapeString(m.name), template.HTMLEscapeString(m.content))
+		}
+		doc.Find("head").PrependHtml(tags.String())
+	}
+
 	doc.Find("*").Each(func(_ int, node *goquery.Selection) {
 		for _, attr := range node.Get(0).Attr {
 			if !strings.HasPrefix(attr.Key, "@fir:") && !strings.HasPrefix(attr.Key, "x-on:fir:") {
@@ -326,11 +390,18 @@ func query(fi fileInfo) fileInfo {
 		panic(err)
 	}
 	evt := make(eventTemplates)
+	var bindings []eventBinding
+	var diagnostics []Diagnostic
+	lines := newLineFinder(fi.content)
 	doc.Find("*").Each(func(_ int, node *goquery.Selection) {
 		for _, attr := range node.Get(0).Attr {
 			if !strings.HasPrefix(attr.Key, "@fir:") && !strings.HasPrefix(attr.Key, "x-on:fir:") {
 				continue
 			}
+			line := lines.find(attr.Key)
+			invalid := func(reason string) {
+				diagnostics = append(diagnostics, Diagnostic{File: fi.name, Line: line, Attribute: attr.Key, Reason: reason})
+			}
 
 			eventns := strings.TrimPrefix(attr.Key, "@fir:")
 			eventns = strings.TrimPrefix(eventns, "x-on:fir:")
@@ -340,6 +411,10 @@ func query(fi fileInfo) fileInfo {
 			if len(eventnsParts) > 0 {
 				eventns = eventnsParts[0]
 			}
+			// dom events dispatched by the framework e.g. @fir:flash.window
+			if slices.Contains(builtinEvents, eventns) {
+				continue
+			}
 
 			// eventns might have a filter:[e1:ok,e2:ok] containing multiple event:state separated by comma
 			eventnsList, _ := getEventNsList(eventns)
@@ -356,7 +431,7 @@ func query(fi fileInfo) fileInfo {
 
 				// [myevent:ok, myblock]
 				if len(eventnsParts) > 2 {
-					klog.Errorf(eventFormatError(eventns))
+					invalid(eventFormatError(eventns))
 					continue
 				}
 
@@ -365,17 +440,17 @@ func query(fi fileInfo) fileInfo {
 				// [myevent, ok]
 				eventIDParts := strings.SplitN(eventID, ":", -1)
 				if len(eventIDParts) != 2 {
-					klog.Errorf(eventFormatError(eventns))
+					invalid(eventFormatError(eventns))
 					continue
 				}
 				// event name can only be followed by ok, error, pending, done
 				if !slices.Contains([]string{"ok", "error", "pending", "done"}, eventIDParts[1]) {
-					klog.Errorf(eventFormatError(eventns))
+					invalid(eventFormatError(eventns))
 					continue
 				}
 				// assert myevent:ok::myblock or myevent:error::myblock
 				if len(eventnsParts) == 2 && !slices.Contains([]string{"ok", "error"}, eventIDParts[1]) {
-					klog.Errorf(eventFormatError(eventns))
+					invalid(eventFormatError(eventns))
 					continue
 
 				}
@@ -391,7 +466,7 @@ func query(fi fileInfo) fileInfo {
 				}
 
 				if !templateNameRegex.MatchString(templateName) {
-					klog.Errorf("error: invalid template name in event binding: only hyphen(-) and colon(:) are allowed: %v\n", templateName)
+					invalid(fmt.Sprintf("invalid template name %s in event binding: only letters, digits, space, hyphen(-) and colon(:) are allowed", templateName))
 					continue
 				}
 
@@ -399,6 +474,7 @@ func query(fi fileInfo) fileInfo {
 				// fmt.Printf("eventID: %s, templateName: %s\n", eventID, templateName)
 
 				evt[eventID] = templates
+				bindings = append(bindings, eventBinding{event: eventIDParts[0], file: fi.name, line: line, attribute: attr.Key})
 
 			}
 
@@ -410,6 +486,8 @@ func query(fi fileInfo) fileInfo {
 		content:        fi.content,
 		err:            fi.err,
 		eventTemplates: evt,
+		bindings:       bindings,
+		diagnostics:    diagnostics,
 	}
 }
 
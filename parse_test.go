@@ -512,3 +512,10 @@ func TestGetEventFilter(t *testing.T) {
 
 	}
 }
+
+func Test_transformMetas(t *testing.T) {
+	got := string(transform([]byte(`<html><head><title>t</title></head><body><div @fir:inc:ok="">0</div></body></html>`),
+		meta{name: "fir-session-id", content: `a"b`}, meta{name: "fir-transport", content: "sse"}))
+	assert.Contains(t, got, `<head><meta name="fir-session-id" content="a&#34;b"/><meta name="fir-transport" content="sse"/><title>t</title></head>`)
+	assert.Contains(t, got, `class="fir-inc-ok"`)
+}
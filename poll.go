@@ -0,0 +1,195 @@
+package fir
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/livefir/fir/internal/dom"
+	"k8s.io/klog/v2"
+)
+
+const (
+	// pollIDHeader is the response header carrying the id of a new long polling session
+	// and the request header carrying the id of an existing one.
+	pollIDHeader = "X-FIR-POLL-ID"
+	// maxPollBatches is the maximum number of event batches buffered for a long polling session.
+	// The oldest batches are dropped when the client doesn't poll fast enough.
+	maxPollBatches = 256
+)
+
+var (
+	// pollTimeout is the duration a poll request waits for events before returning an empty response.
+	pollTimeout = 25 * time.Second
+	// pollIdleTimeout is the duration after which a long polling session without poll requests is closed.
+	pollIdleTimeout = time.Minute
+)
+
+func isPollRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.Header.Get("X-FIR-MODE") == "poll"
+}
+
+// onPoll serves a long polling request. The first request of a session(without X-FIR-POLL-ID) creates a session which
+// buffers the dom events rendered for the events published on the routes' channels and returns its id in the X-FIR-POLL-ID header.
+// The subsequent requests wait for buffered events and return them as a json array of []dom.Event batches.
+// A 404 is returned if the session expired and the client must start a new one.
+func onPoll(w http.ResponseWriter, r *http.Request, cntrl *controller) {
+	pollID := r.Header.Get(pollIDHeader)
+	sessionID := cntrl.session(w, r).ID()
+	if pollID == "" {
+		p, err := cntrl.newPoller(r, sessionID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set(pollIDHeader, p.id)
+		writePollBatches(w, nil)
+		return
+	}
+
+	p := cntrl.getPoller(pollID, sessionID)
+	if p == nil {
+		http.Error(w, "long polling session not found", http.StatusNotFound)
+		return
+	}
+
+	timer := time.NewTimer(pollTimeout)
+	defer timer.Stop()
+	for {
+		batches := p.take()
+		if len(batches) > 0 {
+			writePollBatches(w, batches)
+			return
+		}
+		select {
+		case <-p.notify:
+		case <-timer.C:
+			writePollBatches(w, nil)
+			return
+		case <-p.done:
+			http.Error(w, "long polling session closed", http.StatusNotFound)
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func writePollBatches(w http.ResponseWriter, batches [][]dom.Event) {
+	if batches == nil {
+		batches = [][]dom.Event{}
+	}
+	data, err := json.Marshal(batches)
+	if err != nil {
+		klog.Errorf("[onPoll] error: marshaling events %+v, err %v", batches, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// poller is a long polling session. It implements eventConn by buffering the written events until they are polled.
+type poller struct {
+	id string
+	// sessionID is the browser session which created the poller. Polls from other browsers are rejected.
+	sessionID string
+	batches   [][]dom.Event
+	lastPoll  time.Time
+	notify    chan struct{}
+	done      chan struct{}
+	sync.Mutex
+}
+
+func (p *poller) writeEvents(events []dom.Event) error {
+	p.Lock()
+	defer p.Unlock()
+	if len(p.batches) >= maxPollBatches {
+		klog.Warningf("[poller] session %s buffer is full, dropping the oldest events\n", p.id)
+		p.batches = p.batches[1:]
+	}
+	p.batches = append(p.batches, events)
+	select {
+	case p.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// take returns and clears the buffered batches
+func (p *poller) take() [][]dom.Event {
+	p.Lock()
+	defer p.Unlock()
+	p.lastPoll = time.Now()
+	batches := p.batches
+	p.batches = nil
+	return batches
+}
+
+func (p *poller) idle() bool {
+	p.Lock()
+	defer p.Unlock()
+	return time.Since(p.lastPoll) > pollIdleTimeout
+}
+
+// newPoller creates a long polling session subscribed to the routes' channels. The subscriptions outlive the request
+// which created the session, so they use a detached copy of it.
+func (c *controller) newPoller(r *http.Request, sessionID string) (*poller, error) {
+	if !c.track() {
+		return nil, errShuttingDown
+	}
+	p := &poller{
+		id:        shortuuid.New(),
+		sessionID: sessionID,
+		lastPoll:  time.Now(),
+		notify:    make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	r = detachRequest(r)
+	// subscribe before the poll id is returned so that the events published in between are buffered
+	subscribeRoutes(context.Background(), r, newDiscardResponseWriter(), c, p, newConnSessions(r, sessionID), done, wg)
+	c.Lock()
+	c.pollers[p.id] = p
+	c.Unlock()
+
+	go func() {
+		defer c.workers.Done()
+		ticker := time.NewTicker(pollIdleTimeout / 2)
+		defer ticker.Stop()
+	loop:
+		for {
+			select {
+			case <-c.quit:
+				break loop
+			case <-ticker.C:
+				if p.idle() {
+					break loop
+				}
+			}
+		}
+		c.Lock()
+		delete(c.pollers, p.id)
+		c.Unlock()
+		close(p.done)
+		close(done)
+		wg.Wait()
+	}()
+	return p, nil
+}
+
+// getPoller returns the long polling session with the id if it belongs to the browser session
+func (c *controller) getPoller(id, sessionID string) *poller {
+	c.RLock()
+	defer c.RUnlock()
+	p, ok := c.pollers[id]
+	if !ok || p.sessionID != sessionID {
+		return nil
+	}
+	return p
+}
@@ -0,0 +1,82 @@
+package fir
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/livefir/fir/internal/dom"
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLongPoll(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithTransport(LongPoll))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content(`<div @fir:inc:ok::count="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			OnEvent("inc", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+	pollWith := func(client *http.Client, pollID string) (*http.Response, [][]dom.Event) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		req.Header.Set("X-FIR-MODE", "poll")
+		if pollID != "" {
+			req.Header.Set(pollIDHeader, pollID)
+		}
+		resp, err := client.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var batches [][]dom.Event
+		if resp.StatusCode == http.StatusOK {
+			assert.NoError(t, json.NewDecoder(resp.Body).Decode(&batches))
+		}
+		return resp, batches
+	}
+	poll := func(pollID string) (*http.Response, [][]dom.Event) {
+		return pollWith(browser, pollID)
+	}
+
+	resp, batches := poll("")
+	pollID := resp.Header.Get(pollIDHeader)
+	assert.NotEmpty(t, pollID)
+	assert.Empty(t, batches)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// the session is subscribed once its id is returned
+	ps := c.(*controller).pubsub
+	id := "inc"
+	err := ps.Publish(ctx, "anonymous:counter", pubsub.Event{
+		ID:     &id,
+		State:  eventstate.OK,
+		Detail: map[string]any{"count": 1},
+	})
+	assert.NoError(t, err)
+
+	resp, batches = poll(pollID)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Len(t, batches, 1)
+	assert.Equal(t, "fir:inc:ok::count", *batches[0][0].Type)
+	assert.Equal(t, "<span>1</span>", batches[0][0].Detail)
+
+	resp, _ = poll("unknown")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// another browser can't poll the session
+	resp, _ = pollWith(http.DefaultClient, pollID)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	assert.NoError(t, c.Shutdown(ctx))
+}
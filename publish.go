@@ -0,0 +1,190 @@
+package fir
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"k8s.io/klog/v2"
+)
+
+// PublishTarget selects the clients of a route to which the result of an event is published in addition to
+// the current user's channel. See RouteContext.PublishTo.
+type PublishTarget struct {
+	kind string
+	key  string
+}
+
+// User targets the clients of the user with the given id. The user id of a client is the UserKey value of its request context.
+func User(id string) PublishTarget {
+	return PublishTarget{kind: "user", key: id}
+}
+
+// BrowserSession targets the clients of the browser session with the given id. See Session.ID.
+func BrowserSession(id string) PublishTarget {
+	return PublishTarget{kind: "session", key: id}
+}
+
+// Topic targets the clients subscribed to the topic. Clients subscribe to the topics returned by the route's Topics function.
+func Topic(name string) PublishTarget {
+	return PublishTarget{kind: "topic", key: name}
+}
+
+// Broadcast targets all the clients of the route.
+func Broadcast() PublishTarget {
+	return PublishTarget{kind: "broadcast"}
+}
+
+// channel returns the pubsub channel of the target for the route
+func (t PublishTarget) channel(routeID string) string {
+	if t.kind == "broadcast" {
+		return fmt.Sprintf("fir:broadcast:%s", routeID)
+	}
+	return fmt.Sprintf("fir:%s:%s:%s", t.kind, t.key, routeID)
+}
+
+// Topics sets a function which returns the topics a client of the route subscribes to, e.g. "project:42" for /projects/42.
+// Events published with fir.Topic("project:42") are delivered to the clients subscribed to the topic.
+func Topics(f func(r *http.Request) []string) RouteOption {
+	return func(opt *routeOpt) {
+		opt.topicsFunc = f
+	}
+}
+
+// targetChannels returns the channels of the publish targets a client of the route is subscribed to
+func (rt *route) targetChannels(r *http.Request, sessionID string) []string {
+	channels := []string{Broadcast().channel(rt.id)}
+	if userID, ok := r.Context().Value(UserKey).(string); ok && userID != "" {
+		channels = append(channels, User(userID).channel(rt.id))
+	}
+	if sessionID != "" {
+		channels = append(channels, BrowserSession(sessionID).channel(rt.id))
+	}
+	if rt.topicsFunc != nil {
+		for _, topic := range rt.topicsFunc(r) {
+			channels = append(channels, Topic(topic).channel(rt.id))
+		}
+	}
+	return channels
+}
+
+// PublishTo publishes the result of the event handler to the clients of the route selected by the targets,
+// in addition to the current user's clients. The event templates are rendered for each client:
+//
+//	ctx.PublishTo(fir.Topic("project:42"))
+//	return ctx.Data(project)
+//
+// Only successful results are published to the targets; errors are delivered to the client which sent the event.
+func (c RouteContext) PublishTo(targets ...PublishTarget) {
+	if c.targets == nil {
+		klog.Warningf("[PublishTo] event %s: publishing is not supported for onLoad handlers\n", c.event.ID)
+		return
+	}
+	c.targets.add(targets...)
+}
+
+type publishTargets struct {
+	targets []PublishTarget
+	sync.Mutex
+}
+
+func newPublishTargets() *publishTargets {
+	return &publishTargets{}
+}
+
+func (p *publishTargets) add(targets ...PublishTarget) {
+	p.Lock()
+	defer p.Unlock()
+	p.targets = append(p.targets, targets...)
+}
+
+func (p *publishTargets) channels(routeID string) []string {
+	if p == nil {
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	seen := make(map[string]struct{})
+	var channels []string
+	for _, target := range p.targets {
+		channel := target.channel(routeID)
+		if _, ok := seen[channel]; ok {
+			continue
+		}
+		seen[channel] = struct{}{}
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// withPublishID tags a successful event which is also published to the targets set by ctx.PublishTo,
+// since a client can be subscribed to both the user's channel and a target channel
+func withPublishID(eventCtx RouteContext, pubsubEvent pubsub.Event) pubsub.Event {
+	if pubsubEvent.State == eventstate.OK && pubsubEvent.PublishID == "" && len(eventCtx.targets.channels(eventCtx.route.id)) > 0 {
+		pubsubEvent.PublishID = shortuuid.New()
+	}
+	return pubsubEvent
+}
+
+// deliveredEvents remembers the publish ids of the recent events handled by a subscriber
+type deliveredEvents struct {
+	ids   map[string]struct{}
+	order []string
+	sync.Mutex
+}
+
+// deliveredEventsSize is the number of publish ids remembered by a subscriber
+const deliveredEventsSize = 256
+
+func newDeliveredEvents() *deliveredEvents {
+	return &deliveredEvents{ids: make(map[string]struct{})}
+}
+
+// first reports whether the event is received for the first time
+func (d *deliveredEvents) first(pubsubEvent pubsub.Event) bool {
+	if pubsubEvent.PublishID == "" {
+		return true
+	}
+	d.Lock()
+	defer d.Unlock()
+	if _, ok := d.ids[pubsubEvent.PublishID]; ok {
+		return false
+	}
+	d.ids[pubsubEvent.PublishID] = struct{}{}
+	d.order = append(d.order, pubsubEvent.PublishID)
+	if len(d.order) > deliveredEventsSize {
+		delete(d.ids, d.order[0])
+		d.order = d.order[1:]
+	}
+	return true
+}
+
+// publishToTargets publishes a successful event result to the channels of the targets set by ctx.PublishTo
+func publishToTargets(ctx context.Context, eventCtx RouteContext, pubsubEvent pubsub.Event) {
+	if pubsubEvent.State != eventstate.OK {
+		return
+	}
+	for _, channel := range eventCtx.targets.channels(eventCtx.route.id) {
+		if err := eventCtx.route.pubsub.Publish(ctx, channel, pubsubEvent); err != nil {
+			klog.Errorf("[publishToTargets] error publishing event %v to channel %s: %v\n", eventCtx.event.ID, channel, err)
+		}
+	}
+}
+
+// resultEvent returns the pubsub event for the successful result of an event handler: nil or the data set by ctx.Data.
+func resultEvent(ctx RouteContext, result error) pubsub.Event {
+	target := ""
+	event := pubsub.Event{
+		ID:     &ctx.event.ID,
+		State:  eventstate.OK,
+		Target: &target,
+	}
+	if data, ok := result.(*routeData); ok {
+		event.Detail = *data
+	}
+	return withPublishID(ctx, event)
+}
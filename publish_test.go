@@ -0,0 +1,138 @@
+package fir
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishTo(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), UserKey, r.URL.Query().Get("user"))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("projects"),
+			Content(`<div @fir:rename:ok::title="">{{block "title" .}}<span>{{.title}}</span>{{end}}</div>`),
+			Topics(func(r *http.Request) []string {
+				return []string{"project:" + r.URL.Query().Get("project")}
+			}),
+			OnEventOf("rename", func(ctx RouteContext, req struct {
+				Title  string `json:"title"`
+				Target string `json:"target"`
+			}) error {
+				switch req.Target {
+				case "topic":
+					ctx.PublishTo(Topic("project:7"))
+				case "user":
+					ctx.PublishTo(User("bob"))
+				case "broadcast":
+					ctx.PublishTo(Broadcast())
+				}
+				return ctx.KV("title", req.Title)
+			}),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	sessionID := getPageSessionID(t, srv.URL+"/?user=alice")
+	dial := func(query string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/?"+query, nil)
+		assert.NoError(t, err)
+		return conn
+	}
+	alice := dial("user=alice&project=42")
+	defer alice.Close()
+	bob := dial("user=bob&project=7")
+	defer bob.Close()
+	carol := dial("user=carol&project=42")
+	defer carol.Close()
+	for _, channel := range []string{"bob:projects", "carol:projects", "alice:projects"} {
+		for !c.(*controller).pubsub.HasSubscribers(context.Background(), channel) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	readMessage := func(conn *websocket.Conn) string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return ""
+		}
+		return string(message)
+	}
+
+	for _, target := range []string{"topic", "user"} {
+		err := alice.WriteJSON(map[string]any{"event_id": "rename", "session_id": sessionID,
+			"params": map[string]string{"title": target, "target": target}})
+		assert.NoError(t, err)
+		assert.Contains(t, readMessage(alice), `\u003cspan\u003e`+target)
+		assert.Contains(t, readMessage(bob), `\u003cspan\u003e`+target)
+	}
+
+	err := alice.WriteJSON(map[string]any{"event_id": "rename", "session_id": sessionID,
+		"params": map[string]string{"title": "everyone", "target": "broadcast"}})
+	assert.NoError(t, err)
+	// carol is neither on topic project:7 nor bob, the broadcast is her first event
+	assert.Contains(t, readMessage(carol), `\u003cspan\u003eeveryone`)
+}
+
+func TestPublishToDeliversOnceToSender(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("projects"),
+			Content(`<div @fir:rename:ok::title="">{{block "title" .}}<span>{{.title}}</span>{{end}}</div>`),
+			OnEventOf("rename", func(ctx RouteContext, req struct {
+				Title     string `json:"title"`
+				Broadcast bool   `json:"broadcast"`
+			}) error {
+				if req.Broadcast {
+					ctx.PublishTo(Broadcast(), Broadcast())
+				}
+				return ctx.KV("title", req.Title)
+			}),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	sessionID := getPageSessionID(t, srv.URL)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	for _, channel := range []string{"anonymous:projects", "fir:broadcast:projects"} {
+		for !c.(*controller).pubsub.HasSubscribers(context.Background(), channel) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	readMessage := func() string {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, message, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		return string(message)
+	}
+
+	err = conn.WriteJSON(map[string]any{"event_id": "rename", "session_id": sessionID,
+		"params": map[string]any{"title": "everyone", "broadcast": true}})
+	assert.NoError(t, err)
+	assert.Contains(t, readMessage(), `\u003cspan\u003eeveryone`)
+
+	// the next message is the next event, not the broadcast received on the user's channel
+	err = conn.WriteJSON(map[string]any{"event_id": "rename", "session_id": sessionID,
+		"params": map[string]any{"title": "next"}})
+	assert.NoError(t, err)
+	assert.Contains(t, readMessage(), `\u003cspan\u003enext`)
+}
@@ -22,6 +22,9 @@ type Event struct {
 	Detail     any             `json:"detail"`
 	SessionID  *string         `json:"session_id"`
 	ElementKey *string         `json:"element_key"`
+	// PublishID identifies an event published to several channels so that a subscriber of more than one of them
+	// handles it once
+	PublishID string `json:"publish_id,omitempty"`
 }
 
 // Subscription is a subscription to a channel.
@@ -52,8 +55,47 @@ func NewInmem() Adapter {
 type subscriptionInmem struct {
 	channel string
 	ch      chan Event
+	done    chan struct{}
 	once    sync.Once
 	pubsub  *pubsubInmem
+	// queue holds the published events until they are received, in the order they were published
+	queue  []Event
+	queued chan struct{}
+	mu     sync.Mutex
+}
+
+// send queues the event without waiting for the subscriber
+func (s *subscriptionInmem) send(event Event) {
+	s.mu.Lock()
+	s.queue = append(s.queue, event)
+	s.mu.Unlock()
+	select {
+	case s.queued <- struct{}{}:
+	default:
+	}
+}
+
+// deliver delivers the queued events in order until the subscription is closed, then closes ch
+func (s *subscriptionInmem) deliver() {
+	defer close(s.ch)
+	for {
+		s.mu.Lock()
+		events := s.queue
+		s.queue = nil
+		s.mu.Unlock()
+		for _, event := range events {
+			select {
+			case s.ch <- event:
+			case <-s.done:
+				return
+			}
+		}
+		select {
+		case <-s.queued:
+		case <-s.done:
+			return
+		}
+	}
 }
 
 // C returns a receive-only go channel of events published
@@ -75,7 +117,7 @@ type pubsubInmem struct {
 
 func (p *pubsubInmem) removeSubscription(subscription *subscriptionInmem) {
 	subscription.once.Do(func() {
-		close(subscription.ch)
+		close(subscription.done)
 	})
 
 	subscriptions, ok := p.channelsSubscriptions[subscription.channel]
@@ -105,7 +147,7 @@ func (p *pubsubInmem) Publish(ctx context.Context, channel string, event Event)
 	}
 
 	for subscription := range subscriptions {
-		go func(sub *subscriptionInmem) { sub.ch <- event }(subscription)
+		subscription.send(event)
 	}
 
 	return nil
@@ -121,8 +163,11 @@ func (p *pubsubInmem) Subscribe(ctx context.Context, channel string) (Subscripti
 	sub := &subscriptionInmem{
 		channel: channel,
 		ch:      make(chan Event),
+		done:    make(chan struct{}),
+		queued:  make(chan struct{}, 1),
 		pubsub:  p,
 	}
+	go sub.deliver()
 
 	subs, ok := p.channelsSubscriptions[channel]
 	if !ok {
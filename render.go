@@ -2,7 +2,6 @@ package fir
 
 import (
 	"fmt"
-	"html/template"
 
 	"github.com/livefir/fir/internal/dom"
 	"github.com/livefir/fir/internal/eventstate"
@@ -70,7 +69,11 @@ func buildDOMEventFromTemplate(ctx RouteContext, pubsubEvent pubsub.Event, event
 		}
 		templateData = map[string]any{"fir": newRouteDOMContext(ctx, errs)}
 	}
-	value, err := buildTemplateValue(ctx.route.template, templateName, templateData)
+	var domCtx *RouteDOMContext
+	if pubsubEvent.State == eventstate.OK {
+		templateData, domCtx = withRouteDOMContext(ctx, pubsubEvent.Detail)
+	}
+	value, err := renderBlock(ctx, pubsubEvent, templateName, templateData, domCtx)
 	if err != nil {
 		klog.Errorf("Bindings.Events buildTemplateValue error for eventType: %v, err: %v", *eventType, err)
 		return nil
@@ -90,6 +93,33 @@ func buildDOMEventFromTemplate(ctx RouteContext, pubsubEvent pubsub.Event, event
 
 }
 
+// withRouteDOMContext adds the route context of the client as .fir to the map data of an ok event
+// so that the event blocks can use the same template funcs as the page e.g. {{csrfField .fir}}
+func withRouteDOMContext(ctx RouteContext, detail any) (any, *RouteDOMContext) {
+	var data map[string]any
+	switch detail := detail.(type) {
+	case routeData:
+		data = detail
+	case map[string]any:
+		data = detail
+	default:
+		return detail, nil
+	}
+	if _, ok := data["fir"]; ok {
+		return detail, nil
+	}
+	domCtx := newRouteDOMContext(ctx, nil)
+	templateData := make(map[string]any, len(data)+1)
+	for k, v := range data {
+		templateData[k] = v
+	}
+	templateData["fir"] = domCtx
+	if _, ok := detail.(routeData); ok {
+		return routeData(templateData), domCtx
+	}
+	return templateData, domCtx
+}
+
 func trackErrors(ctx RouteContext, pubsubEvent pubsub.Event, events []dom.Event) []dom.Event {
 	var prevErrors map[string]string
 	if pubsubEvent.SessionID != nil {
@@ -155,7 +185,27 @@ func trackErrors(ctx RouteContext, pubsubEvent pubsub.Event, events []dom.Event)
 	return newEvents
 }
 
-func buildTemplateValue(t *template.Template, templateName string, data any) (string, error) {
+// renderBlock renders the block bound to the event. The blocks of ok events are cached if WithBlockCache is set.
+// The cache key is built from the event data and the client's route context since the block can depend on both.
+func renderBlock(ctx RouteContext, pubsubEvent pubsub.Event, templateName string, data any, domCtx *RouteDOMContext) (string, error) {
+	tmpl := ctx.route.template
+	if ctx.route.blockCache == nil || pubsubEvent.State != eventstate.OK {
+		return buildTemplateValue(tmpl, templateName, data)
+	}
+	keyData := pubsubEvent.Detail
+	if domCtx != nil {
+		keyData = map[string]any{"data": pubsubEvent.Detail, "fir": domCtx.cacheKey()}
+	}
+	key, ok := blockCacheKey(templateName, keyData)
+	if !ok {
+		return buildTemplateValue(tmpl, templateName, data)
+	}
+	return ctx.route.blockCache.render(key, func() (string, error) {
+		return buildTemplateValue(tmpl, templateName, data)
+	})
+}
+
+func buildTemplateValue(t Template, templateName string, data any) (string, error) {
 	if t == nil {
 		return "", nil
 	}
@@ -167,8 +217,7 @@ func buildTemplateValue(t *template.Template, templateName string, data any) (st
 	if templateName == "_fir_html" {
 		dataBuf.WriteString(data.(string))
 	} else {
-		t.Option("missingkey=zero")
-		err := t.ExecuteTemplate(dataBuf, templateName, data)
+		err := t.ExecuteBlock(dataBuf, templateName, data)
 		if err != nil {
 			return "", err
 		}
@@ -3,11 +3,14 @@ package fir
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	firErrors "github.com/livefir/fir/internal/errors"
 	"github.com/livefir/fir/internal/eventstate"
@@ -113,20 +116,14 @@ func Extensions(extensions ...string) RouteOption {
 // FuncMap appends to the default template function map for the route's template engine
 func FuncMap(funcMap template.FuncMap) RouteOption {
 	return func(opt *routeOpt) {
-		mergedFuncMap := make(template.FuncMap)
-		for k, v := range opt.funcMap {
-			mergedFuncMap[k] = v
-		}
-		for k, v := range defaultFuncMap() {
-			mergedFuncMap[k] = v
-		}
-		opt.funcMap = mergedFuncMap
+		opt.funcMap = mergeFuncMaps(opt.funcMap, funcMap)
 	}
 }
 
 // EventSender sets the event sender for the route. It can be used to send events for the route
 // without a corresponding user event. This is useful for sending events to the route event handler for use cases like:
-// sending notifications, sending emails, etc.
+// sending notifications, sending emails, etc. Each event is emitted once with Controller.Emit and broadcast to all the clients of the route.
+// The handlers run with a synthetic request without a user, see Controller.Emit. A panicking handler is logged and the next event is emitted.
 func EventSender(eventSender chan Event) RouteOption {
 	return func(opt *routeOpt) {
 		opt.eventSender = eventSender
@@ -151,6 +148,29 @@ func OnEvent(name string, onEventFunc OnEventFunc) RouteOption {
 	}
 }
 
+// OnEventOf registers an event handler which receives the event params bound into T, a struct.
+// The params are bound and validated as by RouteContext.BindAndValidate before the handler is called;
+// binding and validation failures are returned as field errors without calling the handler.
+func OnEventOf[T any](name string, fn func(ctx RouteContext, params T) error) RouteOption {
+	return OnEvent(name, bindParams(fn))
+}
+
+// OnLoadOf registers the route's onLoad handler which receives the path and query params bound into T, a struct.
+// See OnEventOf.
+func OnLoadOf[T any](fn func(ctx RouteContext, params T) error) RouteOption {
+	return OnLoad(bindParams(fn))
+}
+
+func bindParams[T any](fn func(ctx RouteContext, params T) error) OnEventFunc {
+	return func(ctx RouteContext) error {
+		var params T
+		if err := ctx.BindAndValidate(&params); err != nil {
+			return err
+		}
+		return fn(ctx, params)
+	}
+}
+
 type routeData map[string]any
 
 func (r *routeData) Error() string {
@@ -172,6 +192,9 @@ type routeOpt struct {
 	extensions             []string
 	funcMap                template.FuncMap
 	eventSender            chan Event
+	topicsFunc             func(r *http.Request) []string
+	timeout                time.Duration
+	eventTimeouts          map[string]time.Duration
 	onLoad                 OnEventFunc
 	onEvents               map[string]OnEventFunc
 	opt
@@ -179,10 +202,13 @@ type routeOpt struct {
 
 type route struct {
 	cntrl          *controller
-	template       *template.Template
-	errorTemplate  *template.Template
+	group          *routeGroup
+	template       Template
+	errorTemplate  Template
 	allTemplates   []string
 	eventTemplates eventTemplates
+	parseErr       error
+	blockCache     *blockCache
 
 	routeOpt
 	sync.RWMutex
@@ -190,12 +216,20 @@ type route struct {
 
 func newRoute(cntrl *controller, routeOpt *routeOpt) *route {
 	routeOpt.opt = cntrl.opt
+	routeOpt.funcMap = cntrl.routeFuncMap(routeOpt.funcMap)
 	rt := &route{
 		routeOpt:       *routeOpt,
 		cntrl:          cntrl,
 		eventTemplates: make(eventTemplates),
 	}
+	if rt.blockCacheSize > 0 {
+		rt.blockCache = newBlockCache(rt.blockCacheSize, rt.blockCacheTTL)
+	}
 	rt.parseTemplates()
+	// fail fast unless the template errors are reported as diagnostics
+	if rt.parseErr != nil && !cntrl.templateDiagnostics && !cntrl.validateTemplates {
+		panic(rt.parseErr)
+	}
 	return rt
 }
 
@@ -205,55 +239,69 @@ func renderRoute(ctx RouteContext, errorRouteTemplate bool) routeRenderer {
 		buf := bytebufferpool.Get()
 		defer bytebufferpool.Put(buf)
 
+		if domCtx, ok := data["fir"].(*RouteDOMContext); ok {
+			domCtx.Flashes = ctx.takeFlashes()
+		}
+
 		tmpl := ctx.route.template
 		if errorRouteTemplate {
 			tmpl = ctx.route.errorTemplate
 		}
-		tmpl.Option("missingkey=zero")
+		// nothing is written to the response until the page is rendered so the errors are written as a 500
+		if tmpl == nil {
+			err := fmt.Errorf("route %s: %w", ctx.route.id, ctx.route.parseErr)
+			klog.Errorf("[renderRoute] error parsing templates: %v\n", err)
+			http.Error(ctx.response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return err
+		}
 		err := tmpl.Execute(buf, data)
 		if err != nil {
 			klog.Errorf("[renderRoute] error executing template: %v\n", err)
+			http.Error(ctx.response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 			return err
 		}
 
-		// encodedRouteID, err := ctx.route.cntrl.secureCookie.Encode(ctx.route.cookieName, ctx.route.id)
-		// if err != nil {
-		// 	klog.Errorf("[renderRoute] error encoding cookie: %v\n", err)
-		// 	return err
-		// }
-
-		http.SetCookie(ctx.response, &http.Cookie{
-			Name:   ctx.route.cookieName,
-			Value:  ctx.route.id,
-			MaxAge: 0,
-			Path:   "/",
-		})
+		sessionID, err := ctx.route.cntrl.newPageSessionID(ctx.route.id)
+		if err != nil {
+			klog.Errorf("[renderRoute] error encoding page session id: %v\n", err)
+			http.Error(ctx.response, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return err
+		}
 
-		ctx.response.Write(transform(buf.Bytes()))
+		metas := []meta{{name: "fir-session-id", content: sessionID}}
+		if ctx.route.transport != Websocket {
+			metas = append(metas, meta{name: "fir-transport", content: string(ctx.route.transport)})
+		}
+		html := transform(buf.Bytes(), metas...)
+		ctx.response.Write(html)
 		return nil
 	}
 }
 
 func publishEvents(ctx context.Context, eventCtx RouteContext) eventPublisher {
 	return func(pubsubEvent pubsub.Event) error {
+		pubsubEvent = withPublishID(eventCtx, pubsubEvent)
 		channel := eventCtx.route.channelFunc(eventCtx.request, eventCtx.route.id)
 		err := eventCtx.route.pubsub.Publish(ctx, *channel, pubsubEvent)
 		if err != nil {
 			klog.Errorf("[onWebsocket][getEventPatchset] error publishing patch: %v\n", err)
 			return err
 		}
+		publishToTargets(ctx, eventCtx, pubsubEvent)
 		return nil
 	}
 }
 
 func writeAndPublishEvents(ctx RouteContext) eventPublisher {
 	return func(pubsubEvent pubsub.Event) error {
+		pubsubEvent = withPublishID(ctx, pubsubEvent)
 		channel := ctx.route.channelFunc(ctx.request, ctx.route.id)
 		err := ctx.route.pubsub.Publish(ctx.request.Context(), *channel, pubsubEvent)
 		if err != nil {
 			klog.Warningf("[writeAndPublishEvents] error publishing patch: %v\n", err)
 		}
-		events := renderDOMEvents(ctx, pubsubEvent)
+		publishToTargets(ctx.request.Context(), ctx, pubsubEvent)
+		events := append(renderDOMEvents(ctx, pubsubEvent), ctx.pending.take()...)
 
 		eventsData, err := json.Marshal(events)
 		if err != nil {
@@ -265,16 +313,23 @@ func writeAndPublishEvents(ctx RouteContext) eventPublisher {
 	}
 }
 
+// ServeHTTP serves the route wrapped by the middlewares of the controller and the route's group.
 func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.group.wrap(http.HandlerFunc(rt.serveHTTP)).ServeHTTP(w, r)
+}
+
+func (rt *route) serveHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.URL.Path == "/favicon.ico" {
 		http.NotFound(w, r)
 		return
 	}
+	// r is replaced by the request with the path params, whose form is parsed
+	defer func() { removeMultipartForm(r) }()
 
 	if r.Header.Get("Connection") == "Upgrade" &&
 		r.Header.Get("Upgrade") == "websocket" {
 		// onWebsocket: upgrade to websocket
-		if rt.disableWebsocket {
+		if rt.disableWebsocket || rt.transport != Websocket {
 			http.Error(w, "websocket is disabled", http.StatusForbidden)
 			return
 		}
@@ -287,10 +342,30 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if r.Header.Get("Connection") == "Upgrade" &&
 		r.Header.Get("Upgrade") == "websocket" {
 		onWebsocket(w, r, rt.cntrl)
+	} else if isSSERequest(r) {
+		onSSE(w, r, rt.cntrl)
+	} else if isPollRequest(r) {
+		onPoll(w, r, rt.cntrl)
+	} else if wantsJSON(r) {
+		onJSON(w, r, rt)
 	} else if r.Header.Get("X-FIR-MODE") == "event" && r.Method == http.MethodPost {
 		// onEvents
+		var body io.Reader = r.Body
+		if isMultipartRequest(r) {
+			// file uploads: the event is sent in a form field along with the files
+			if status, err := parseForm(w, r, rt.maxUploadSize); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+			eventValue := r.MultipartForm.Value[firEventField]
+			if len(eventValue) == 0 {
+				http.Error(w, fmt.Sprintf("%s field is missing", firEventField), http.StatusBadRequest)
+				return
+			}
+			body = strings.NewReader(eventValue[0])
+		}
 		var event Event
-		decoder := json.NewDecoder(r.Body)
+		decoder := json.NewDecoder(body)
 		decoder.DisallowUnknownFields()
 		err := decoder.Decode(&event)
 		if err != nil {
@@ -311,6 +386,9 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			request:  r,
 			response: w,
 			route:    rt,
+			session:  rt.cntrl.session(w, r),
+			pending:  newPendingEvents(),
+			targets:  newPublishTargets(),
 		}
 
 		onEventFunc, ok := rt.onEvents[strings.ToLower(event.ID)]
@@ -319,31 +397,19 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		handleOnEventResult(onEventFunc(eventCtx), eventCtx, writeAndPublishEvents(eventCtx))
+		handleOnEventResult(eventCtx.call(onEventFunc), eventCtx, writeAndPublishEvents(eventCtx))
 
 	} else {
 		// postForm
 		if r.Method == http.MethodPost {
-			formAction := ""
-			values := r.URL.Query()
-			if len(values) == 1 {
-				event := values.Get("event")
-				if event != "" {
-					formAction = event
-				}
-			}
-			if formAction == "" && len(rt.onEvents) > 1 {
-				http.Error(w, "form action[?event=myaction] is missing and default onEvent can't be selected since there is more than 1", http.StatusBadRequest)
+			formAction, err := rt.formAction(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
-			} else if formAction == "" && len(rt.onEvents) == 1 {
-				for k := range rt.onEvents {
-					formAction = k
-				}
 			}
 
-			err := r.ParseForm()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
+			if status, err := parseForm(w, r, rt.maxUploadSize); err != nil {
+				http.Error(w, err.Error(), status)
 				return
 			}
 			urlValues := r.PostForm
@@ -364,6 +430,8 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				response:  w,
 				route:     rt,
 				urlValues: urlValues,
+				session:   rt.cntrl.session(w, r),
+				targets:   newPublishTargets(),
 			}
 
 			onEventFunc, ok := rt.onEvents[event.ID]
@@ -372,7 +440,11 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			handlePostFormResult(onEventFunc(eventCtx), eventCtx)
+			result := eventCtx.call(onEventFunc)
+			if result == nil || isRouteData(result) {
+				publishToTargets(r.Context(), eventCtx, resultEvent(eventCtx, result))
+			}
+			handlePostFormResult(result, eventCtx)
 
 		} else if r.Method == http.MethodGet {
 			// onLoad
@@ -383,14 +455,35 @@ func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 				response: w,
 				route:    rt,
 				isOnLoad: true,
+				session:  rt.cntrl.session(w, r),
 			}
-			handleOnLoadResult(rt.onLoad(eventCtx), nil, eventCtx)
+			handleOnLoadResult(eventCtx.call(rt.onLoad), nil, eventCtx)
 		} else {
 			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		}
 	}
 }
 
+// formAction returns the event id of a form post: the ?event=myaction query param or the route's only onEvent handler.
+func (rt *route) formAction(r *http.Request) (string, error) {
+	formAction := ""
+	values := r.URL.Query()
+	if len(values) == 1 {
+		event := values.Get("event")
+		if event != "" {
+			formAction = event
+		}
+	}
+	if formAction == "" && len(rt.onEvents) > 1 {
+		return "", errors.New("form action[?event=myaction] is missing and default onEvent can't be selected since there is more than 1")
+	} else if formAction == "" && len(rt.onEvents) == 1 {
+		for k := range rt.onEvents {
+			formAction = k
+		}
+	}
+	return formAction, nil
+}
+
 func handleOnEventResult(err error, ctx RouteContext, publish eventPublisher) {
 	target := ""
 	if ctx.event.Target != nil {
@@ -467,6 +560,9 @@ func handleOnEventResult(err error, ctx RouteContext, publish eventPublisher) {
 }
 
 func handlePostFormResult(err error, ctx RouteContext) {
+	if ctx.redirected() {
+		return
+	}
 	if err == nil {
 		http.Redirect(ctx.response, ctx.request, ctx.request.URL.Path, http.StatusFound)
 		return
@@ -476,11 +572,14 @@ func handlePostFormResult(err error, ctx RouteContext) {
 	case *routeData:
 		http.Redirect(ctx.response, ctx.request, ctx.request.URL.Path, http.StatusFound)
 	default:
-		handleOnLoadResult(ctx.route.onLoad(ctx), err, ctx)
+		handleOnLoadResult(ctx.call(ctx.route.onLoad), err, ctx)
 	}
 }
 
 func handleOnLoadResult(err, onFormErr error, ctx RouteContext) {
+	if ctx.redirected() {
+		return
+	}
 	if err == nil {
 		errs := make(map[string]any)
 		if onFormErr != nil {
@@ -571,6 +670,10 @@ func handleOnLoadResult(err, onFormErr error, ctx RouteContext) {
 					"onload":     fmt.Sprintf("%v", errVal),
 				}
 			}
+		} else if fieldErrorsVal, ok := err.(*firErrors.Fields); ok {
+			// e.g. binding errors of OnLoadOf: {{.fir.Error "onload.field"}}
+			errs = map[string]any{
+				"onload": fieldErrorsVal.Map()}
 		} else {
 			errs = map[string]any{
 				"onload": err.Error()}
@@ -581,20 +684,29 @@ func handleOnLoadResult(err, onFormErr error, ctx RouteContext) {
 }
 
 func (rt *route) parseTemplates() {
-	var err error
-	if rt.template == nil || (rt.template != nil && rt.disableTemplateCache) {
-		var successEventTemplates eventTemplates
-		rt.template, successEventTemplates, err = parseTemplate(rt.routeOpt)
+	// a failed parse is retried only if the templates are not cached, i.e. when they are being edited
+	if (rt.template == nil && rt.parseErr == nil) || rt.disableTemplateCache {
+		rt.Lock()
+		defer rt.Unlock()
+		// a template error is reported by renderRoute and Validate instead of bringing down the server
+		successTemplate, err := rt.templateEngine.Parse(rt.templateConfig(false))
 		if err != nil {
-			panic(err)
+			rt.templateError(err)
+			return
 		}
-		var errorEventTemplates eventTemplates
-		rt.errorTemplate, errorEventTemplates, err = parseErrorTemplate(rt.routeOpt)
+		errorTemplate, err := rt.templateEngine.Parse(rt.templateConfig(true))
 		if err != nil {
-			panic(err)
+			rt.templateError(err)
+			return
+		}
+		rt.template, rt.errorTemplate, rt.parseErr = successTemplate, errorTemplate, nil
+		if rt.blockCache != nil {
+			rt.blockCache.clear()
 		}
 
-		rt.eventTemplates = deepMergeEventTemplates(errorEventTemplates, successEventTemplates)
+		rt.eventTemplates = deepMergeEventTemplates(
+			eventTemplatesOf(errorTemplate.EventBindings()),
+			eventTemplatesOf(successTemplate.EventBindings()))
 		for eventID, templates := range rt.eventTemplates {
 			var templatesStr string
 			for k := range templates {
@@ -611,11 +723,11 @@ func (rt *route) parseTemplates() {
 	}
 }
 
-func (rt *route) findAllTemplates() {
-	rt.allTemplates = []string{}
-	for _, t := range rt.template.Templates() {
-		tName := t.Name()
-		rt.allTemplates = append(rt.allTemplates, tName)
+func (rt *route) templateError(err error) {
+	klog.Errorf("[parseTemplates] route %s: error parsing templates: %v\n", rt.id, err)
+	rt.template, rt.errorTemplate, rt.parseErr = nil, nil, err
+}
 
-	}
+func (rt *route) findAllTemplates() {
+	rt.allTemplates = append([]string{}, rt.template.Blocks()...)
 }
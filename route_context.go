@@ -2,6 +2,7 @@ package fir
 
 import (
 	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"errors"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/fatih/structs"
 
+	"github.com/livefir/fir/internal/dom"
 	firErrors "github.com/livefir/fir/internal/errors"
 )
 
@@ -40,13 +42,19 @@ type RouteContext struct {
 	urlValues url.Values
 	route     *route
 	isOnLoad  bool
+	session   *Session
+	// pending are the dom events for the client which sent the event, nil for page requests
+	pending *pendingEvents
+	// targets are the publish targets set by PublishTo, nil for onLoad handlers
+	targets *publishTargets
 }
 
 func (c RouteContext) Event() Event {
 	return c.event
 }
 
-// Bind decodes the event params into the given struct
+// Bind decodes the path params, query params and event params into the given struct using the controller's Binder.
+// A value from a later source overrides the value of an earlier one: path params < query params < event params.
 func (c RouteContext) Bind(v any) error {
 	if v == nil {
 		return errors.New("bind value cannot be nil")
@@ -72,6 +80,7 @@ func (c RouteContext) Bind(v any) error {
 	return c.BindEventParams(v)
 }
 
+// BindPathParams decodes the route's path params into the given struct
 func (c RouteContext) BindPathParams(v any) error {
 	if v == nil {
 		return nil // nothing to bind
@@ -90,33 +99,22 @@ func (c RouteContext) BindPathParams(v any) error {
 	if !ok {
 		return nil
 	}
-	s := structs.New(v)
-	for _, field := range s.Fields() {
-		if field.IsExported() {
-			if v, ok := pathParams[field.Tag("json")]; ok {
-				err := field.Set(v)
-				if err != nil {
-					return err
-				}
-				continue
-			}
-
-			if v, ok := pathParams[field.Tag("json")]; ok {
-				err := field.Set(v)
-				if err != nil {
-					return err
-				}
-			}
-		}
-	}
-	return nil
+	return c.route.binder.BindPathParams(v, pathParams)
 }
 
+// BindQueryParams decodes the url query params into the given struct
 func (c RouteContext) BindQueryParams(v any) error {
-	return c.route.formDecoder.Decode(v, c.request.URL.Query())
+	return c.route.binder.BindQueryParams(v, c.request.URL.Query())
 }
 
+// BindEventParams decodes the event params into the given struct. The files uploaded with a multipart form are bound
+// to the *multipart.FileHeader and []*multipart.FileHeader fields.
 func (c RouteContext) BindEventParams(v any) error {
+	if c.request != nil {
+		if err := bindFiles(v, c.request.MultipartForm); err != nil {
+			return err
+		}
+	}
 	if c.event.Params == nil {
 		return nil
 	}
@@ -128,10 +126,32 @@ func (c RouteContext) BindEventParams(v any) error {
 			}
 			c.urlValues = urlValues
 		}
-		return c.route.formDecoder.Decode(v, c.urlValues)
+		return c.route.binder.BindForm(v, c.urlValues)
 	}
 
-	return json.NewDecoder(bytes.NewReader(c.event.Params)).Decode(v)
+	return c.route.binder.BindJSON(v, c.event.Params)
+}
+
+// Session returns the browser session. Its values persist across page loads, form posts and events
+// of the same browser session. See SessionStore.
+func (c RouteContext) Session() *Session {
+	return c.session
+}
+
+// call runs the handler and saves the session values it modified before the response is written
+func (c RouteContext) call(f OnEventFunc) error {
+	ctx, cancel := c.route.handlerContext(c.request.Context(), c.event.ID)
+	defer cancel()
+	c.request = c.request.WithContext(ctx)
+	err := f(c)
+	c.session.save(c.response)
+	return err
+}
+
+// Context returns the context of the event. It is cancelled when the client goes away, e.g. the websocket connection
+// which sent the event is closed, or when the handler's timeout expires. See WithEventTimeout and EventTimeout.
+func (c RouteContext) Context() context.Context {
+	return c.request.Context()
 }
 
 // Request returns the http.Request for the current context
@@ -144,7 +164,8 @@ func (c RouteContext) Response() http.ResponseWriter {
 	return c.response
 }
 
-// Redirect redirects the client to the given url
+// Redirect redirects the client to the given url. For events sent by the fir client the redirect is delivered
+// as a fir:redirect dom event since the response of a websocket event can't redirect.
 func (c RouteContext) Redirect(url string, status int) error {
 	if url == "" {
 		return errors.New("url is required")
@@ -152,10 +173,40 @@ func (c RouteContext) Redirect(url string, status int) error {
 	if status < 300 || status > 308 {
 		return errors.New("status code must be between 300 and 308")
 	}
+	if c.pending != nil {
+		// the client which sent the event navigates to the url
+		c.pending.add(navigationEvent("fir:redirect", url))
+		return nil
+	}
 	http.Redirect(c.response, c.request, url, status)
 	return nil
 }
 
+// PushURL adds the url to the browser history of the client which sent the event without reloading the page,
+// e.g. to reflect query state like ?page=2. It has no effect for page requests.
+func (c RouteContext) PushURL(url string) {
+	if c.pending != nil && url != "" {
+		c.pending.add(navigationEvent("fir:push-url", url))
+	}
+}
+
+// ReplaceURL replaces the current url in the browser history of the client which sent the event without reloading the page.
+// It has no effect for page requests.
+func (c RouteContext) ReplaceURL(url string) {
+	if c.pending != nil && url != "" {
+		c.pending.add(navigationEvent("fir:replace-url", url))
+	}
+}
+
+func navigationEvent(eventType, url string) dom.Event {
+	return dom.Event{Type: &eventType, Detail: map[string]string{"url": url}}
+}
+
+// redirected returns true if the handler redirected the page request
+func (c RouteContext) redirected() bool {
+	return c.response.Header().Get("Location") != ""
+}
+
 // KV is a wrapper for ctx.Data(map[string]any{key: data})
 func (c RouteContext) KV(key string, data any) error {
 	return c.Data(map[string]any{key: data})
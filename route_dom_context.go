@@ -3,23 +3,36 @@ package fir
 import (
 	"encoding/json"
 	"strings"
-
-	"github.com/tidwall/gjson"
 )
 
 func newRouteDOMContext(ctx RouteContext, errs map[string]any) *RouteDOMContext {
-	return &RouteDOMContext{
+	domCtx := &RouteDOMContext{
 		URLPath: ctx.request.URL.Path,
 		Name:    ctx.route.appName,
 		errors:  errs,
 	}
+	if ctx.route.csrfToken != nil {
+		domCtx.csrfFieldName = ctx.route.csrfFieldName
+		domCtx.csrfToken = ctx.route.csrfToken(ctx.request)
+	}
+	return domCtx
 }
 
 // RouteDOMContext is a struct that holds route context data and is passed to the template
 type RouteDOMContext struct {
 	Name    string
 	URLPath string
+	// Flashes are the flash messages added by ctx.Flash since the last rendered page
+	Flashes []Flash
 	errors  map[string]any
+
+	csrfFieldName string
+	csrfToken     string
+}
+
+// cacheKey returns the values of the context which a rendered event block can depend on. See renderBlock.
+func (rc *RouteDOMContext) cacheKey() []string {
+	return []string{rc.Name, rc.URLPath, rc.csrfFieldName, rc.csrfToken}
 }
 
 // ActiveRoute returns the class if the route is active
@@ -44,13 +57,39 @@ func (rc *RouteDOMContext) NotActiveRoute(path, class string) string {
 // It can be used in conjunction with ctx.FieldError to get the error for a field
 func (rc *RouteDOMContext) Error(paths ...string) any {
 	data, _ := json.Marshal(rc.errors)
-	val := gjson.GetBytes(data, getErrorLookupPath(paths...)).Value()
+	var errs any
+	if err := json.Unmarshal(data, &errs); err != nil {
+		return nil
+	}
+	val := lookupError(errs, strings.Split(getErrorLookupPath(paths...), "."))
 	_, ok := val.(map[string]any)
 	if ok {
 		return nil
 	}
 	return val
 }
+
+// lookupError resolves the path in the errors. The errors of nested struct fields are keyed by their json path
+// e.g. owner.email, so the longest key matching the leading segments of the path is tried first at each level.
+func lookupError(errs any, segments []string) any {
+	if len(segments) == 0 {
+		return errs
+	}
+	m, ok := errs.(map[string]any)
+	if !ok {
+		return nil
+	}
+	for i := len(segments); i > 0; i-- {
+		v, ok := m[strings.Join(segments[:i], ".")]
+		if !ok {
+			continue
+		}
+		if val := lookupError(v, segments[i:]); val != nil {
+			return val
+		}
+	}
+	return nil
+}
 func getErrorLookupPath(paths ...string) string {
 	path := ""
 	if len(paths) == 0 {
@@ -0,0 +1,148 @@
+package fir
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// routeMatcher matches a url path against a route pattern like /projects/{id}
+type routeMatcher struct {
+	pattern  string
+	segments []string
+	route    *route
+}
+
+func newRouteMatcher(pattern string, rt *route) *routeMatcher {
+	if pattern == "" || !strings.HasPrefix(pattern, "/") {
+		panic("fir: route pattern must begin with '/'")
+	}
+	segments := splitPath(pattern)
+	seen := make(map[string]struct{})
+	for _, segment := range segments {
+		name, ok := paramName(segment)
+		if !ok {
+			continue
+		}
+		if name == "" {
+			panic("fir: empty path param name in route pattern " + pattern)
+		}
+		if _, ok := seen[name]; ok {
+			panic("fir: duplicate path param " + name + " in route pattern " + pattern)
+		}
+		seen[name] = struct{}{}
+	}
+	return &routeMatcher{
+		pattern:  pattern,
+		segments: segments,
+		route:    rt,
+	}
+}
+
+// match returns the path params extracted from the path and the number of static segments matched.
+// The static segment count is used to prefer /projects/new over /projects/{id}.
+func (m *routeMatcher) match(path string) (PathParams, int, bool) {
+	pathSegments := splitPath(path)
+	if len(pathSegments) != len(m.segments) {
+		return nil, 0, false
+	}
+	params := PathParams{}
+	static := 0
+	for i, segment := range m.segments {
+		if name, ok := paramName(segment); ok {
+			if pathSegments[i] == "" {
+				return nil, 0, false
+			}
+			params[name] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, 0, false
+		}
+		static++
+	}
+	return params, static, true
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return []string{}
+	}
+	return strings.Split(path, "/")
+}
+
+func paramName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+		return strings.TrimSpace(segment[1 : len(segment)-1]), true
+	}
+	return "", false
+}
+
+// Handle registers the route for the given pattern. Patterns can contain named path params
+// like /projects/{id} which are available to the route handlers via ctx.Bind or ctx.BindPathParams.
+func (c *controller) Handle(pattern string, route Route) {
+	c.root.Handle(pattern, route)
+}
+
+// HandleFunc registers the route func for the given pattern. See Handle.
+func (c *controller) HandleFunc(pattern string, routeFunc RouteFunc) {
+	c.root.HandleFunc(pattern, routeFunc)
+}
+
+// Use appends middlewares to the chain wrapping every route of the controller.
+func (c *controller) Use(middlewares ...func(http.Handler) http.Handler) {
+	c.root.Use(middlewares...)
+}
+
+// Group creates a route group mounted under prefix whose routes are wrapped by the given middlewares
+// in addition to the controller's middlewares.
+func (c *controller) Group(prefix string, middlewares ...func(http.Handler) http.Handler) Router {
+	return c.root.Group(prefix, middlewares...)
+}
+
+func (c *controller) handle(group *routeGroup, pattern string, options RouteOptions) {
+	r := c.addRoute(group, options)
+	m := newRouteMatcher(pattern, r)
+	c.Lock()
+	defer c.Unlock()
+	c.matchers = append(c.matchers, m)
+}
+
+func (c *controller) match(path string) (*route, PathParams) {
+	c.RLock()
+	defer c.RUnlock()
+	var matched *routeMatcher
+	var matchedParams PathParams
+	bestScore := -1
+	for _, m := range c.matchers {
+		params, score, ok := m.match(path)
+		if !ok || score <= bestScore {
+			continue
+		}
+		matched = m
+		matchedParams = params
+		bestScore = score
+	}
+	if matched == nil {
+		return nil, nil
+	}
+	return matched.route, matchedParams
+}
+
+// ServeHTTP dispatches the request to the route registered for the request's url path.
+// Page loads, form posts, events and websocket upgrades are all handled by the matched route.
+func (c *controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.routeManifestPath != "" && r.URL.Path == c.routeManifestPath {
+		// the manifest is wrapped by the controller's middlewares so that they can protect it like the routes
+		c.root.wrap(http.HandlerFunc(c.serveRouteManifest)).ServeHTTP(w, r)
+		return
+	}
+	rt, params := c.match(r.URL.Path)
+	if rt == nil {
+		http.NotFound(w, r)
+		return
+	}
+	r = r.WithContext(context.WithValue(r.Context(), PathParamsKey, params))
+	rt.ServeHTTP(w, r)
+}
@@ -0,0 +1,97 @@
+package fir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_routeMatcher_match(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		path       string
+		wantParams PathParams
+		wantOK     bool
+	}{
+		{
+			name:       "root",
+			pattern:    "/",
+			path:       "/",
+			wantParams: PathParams{},
+			wantOK:     true,
+		},
+		{
+			name:       "static path with trailing slash",
+			pattern:    "/about",
+			path:       "/about/",
+			wantParams: PathParams{},
+			wantOK:     true,
+		},
+		{
+			name:       "named param",
+			pattern:    "/projects/{id}",
+			path:       "/projects/42",
+			wantParams: PathParams{"id": "42"},
+			wantOK:     true,
+		},
+		{
+			name:       "multiple named params",
+			pattern:    "/projects/{id}/issues/{issueID}",
+			path:       "/projects/42/issues/7",
+			wantParams: PathParams{"id": "42", "issueID": "7"},
+			wantOK:     true,
+		},
+		{
+			name:    "segment count mismatch",
+			pattern: "/projects/{id}",
+			path:    "/projects/42/show",
+			wantOK:  false,
+		},
+		{
+			name:    "static segment mismatch",
+			pattern: "/projects/{id}/show",
+			path:    "/projects/42/edit",
+			wantOK:  false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newRouteMatcher(tt.pattern, nil)
+			params, _, ok := m.match(tt.path)
+			assert.Equal(t, tt.wantOK, ok)
+			if tt.wantOK {
+				assert.Equal(t, tt.wantParams, params)
+			}
+		})
+	}
+}
+
+func Test_controller_match(t *testing.T) {
+	show := &route{routeOpt: routeOpt{id: "show"}}
+	create := &route{routeOpt: routeOpt{id: "new"}}
+	c := &controller{
+		matchers: []*routeMatcher{
+			newRouteMatcher("/projects/{id}", show),
+			newRouteMatcher("/projects/new", create),
+		},
+	}
+
+	rt, params := c.match("/projects/new")
+	assert.Equal(t, create, rt)
+	assert.Equal(t, PathParams{}, params)
+
+	rt, params = c.match("/projects/42")
+	assert.Equal(t, show, rt)
+	assert.Equal(t, PathParams{"id": "42"}, params)
+
+	rt, _ = c.match("/issues")
+	assert.Nil(t, rt)
+}
+
+func Test_joinPath(t *testing.T) {
+	assert.Equal(t, "/admin", joinPath("/admin", "/"))
+	assert.Equal(t, "/admin/users", joinPath("/admin/", "users"))
+	assert.Equal(t, "/users", joinPath("", "/users"))
+	assert.Equal(t, "/", joinPath("", ""))
+}
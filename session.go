@@ -0,0 +1,205 @@
+package fir
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/lithammer/shortuuid/v4"
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"k8s.io/klog/v2"
+)
+
+// sessionIDCookieName is the name of the cookie which carries the browser session id
+const sessionIDCookieName = "_fir_sid_"
+
+// Session holds the values of a browser session. The values are loaded from the controller's SessionStore on first use and
+// saved after the route handler returns, so they persist across page loads, form posts and events of the same browser session.
+type Session struct {
+	id      string
+	store   SessionStore
+	request *http.Request
+	values  userStore
+	loaded  bool
+	dirty   bool
+	sync.Mutex
+}
+
+func newSession(store SessionStore, r *http.Request, id string) *Session {
+	return &Session{id: id, store: store, request: r}
+}
+
+// ID returns the browser session id
+func (s *Session) ID() string {
+	if s == nil {
+		return ""
+	}
+	return s.id
+}
+
+// Get returns the value for the key or nil if the key is not set
+func (s *Session) Get(key string) any {
+	if s == nil {
+		return nil
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.load()
+	return s.values[key]
+}
+
+// Set sets the value for the key
+func (s *Session) Set(key string, value any) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.load()
+	s.values[key] = value
+	s.dirty = true
+}
+
+// Delete removes the key from the session
+func (s *Session) Delete(key string) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.load()
+	if _, ok := s.values[key]; !ok {
+		return
+	}
+	delete(s.values, key)
+	s.dirty = true
+}
+
+// SessionValue returns the session value for the key as T. ok is false if the key is not set or the value is not a T.
+func SessionValue[T any](s *Session, key string) (value T, ok bool) {
+	value, ok = s.Get(key).(T)
+	return
+}
+
+func (s *Session) load() {
+	if s.loaded {
+		return
+	}
+	s.loaded = true
+	s.values = make(userStore)
+	if s.store == nil {
+		return
+	}
+	values, err := s.store.Load(s.request, s.id)
+	if err != nil {
+		klog.Warningf("[Session] error loading session values, starting with an empty session: %v\n", err)
+		return
+	}
+	for k, v := range values {
+		s.values[k] = v
+	}
+}
+
+// save saves the session values if they were modified
+func (s *Session) save(w http.ResponseWriter) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if !s.dirty || s.store == nil {
+		return
+	}
+	if err := s.store.Save(w, s.request, s.id, s.values); err != nil {
+		klog.Errorf("[Session] error saving session values: %v\n", err)
+		return
+	}
+	s.dirty = false
+}
+
+// session returns the browser session for the request. A new session id is created and set in a cookie
+// if the request doesn't carry a valid one.
+func (c *controller) session(w http.ResponseWriter, r *http.Request) *Session {
+	if cookie, err := r.Cookie(sessionIDCookieName); err == nil {
+		var id string
+		if err := c.decodeCookie(sessionIDCookieName, cookie.Value, &id); err == nil && id != "" {
+			return newSession(c.sessionStore, r, id)
+		}
+	}
+	id := shortuuid.New()
+	encoded, err := c.encodeCookie(sessionIDCookieName, id)
+	if err != nil {
+		klog.Errorf("[session] error encoding session id cookie: %v\n", err)
+		return newSession(c.sessionStore, r, id)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionIDCookieName,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return newSession(c.sessionStore, r, id)
+}
+
+// pageSession identifies a rendered page. Its encoded value is the session id rendered into the page's
+// fir-session-id meta tag and echoed by the client with every event, so that events are dispatched to the route
+// which rendered the page regardless of the other routes open in the same browser.
+type pageSession struct {
+	RouteID string
+	PageID  string
+}
+
+// newPageSessionID returns a new encoded page session id for the route
+func (c *controller) newPageSessionID(routeID string) (string, error) {
+	return c.encodeCookie(c.cookieName, pageSession{RouteID: routeID, PageID: shortuuid.New()})
+}
+
+// decodePageSession decodes the page session id sent by the client
+func (c *controller) decodePageSession(sessionID string) (pageSession, error) {
+	var ps pageSession
+	if err := c.decodeCookie(c.cookieName, sessionID, &ps); err != nil {
+		return ps, err
+	}
+	if ps.RouteID == "" || ps.PageID == "" {
+		return ps, fmt.Errorf("route id or page id is empty")
+	}
+	return ps, nil
+}
+
+// connSessions are the browser session and the page sessions served by a client connection. A connection receives the
+// error events of its own pages only since errors are specific to the page which sent the event.
+type connSessions struct {
+	sessionID string
+	ids       map[string]struct{}
+	sync.RWMutex
+}
+
+// newConnSessions creates the set with the browser session id and the session_id query params of the connection request
+func newConnSessions(r *http.Request, sessionID string) *connSessions {
+	s := &connSessions{sessionID: sessionID, ids: make(map[string]struct{})}
+	for _, id := range r.URL.Query()["session_id"] {
+		if id != "" {
+			s.ids[id] = struct{}{}
+		}
+	}
+	return s
+}
+
+func (s *connSessions) add(id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.ids[id] = struct{}{}
+}
+
+// accepts returns false for the error events of other pages
+func (s *connSessions) accepts(pubsubEvent pubsub.Event) bool {
+	if pubsubEvent.SessionID == nil || pubsubEvent.State != eventstate.Error {
+		return true
+	}
+	s.RLock()
+	defer s.RUnlock()
+	_, ok := s.ids[*pubsubEvent.SessionID]
+	return ok
+}
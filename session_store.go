@@ -0,0 +1,205 @@
+package fir
+
+import (
+	"bytes"
+	"context"
+	"encoding/gob"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/gorilla/securecookie"
+)
+
+// SessionStore loads and saves the values of browser sessions. Values are encoded with encoding/gob by the cookie and redis stores,
+// so custom types stored in a session must be registered with gob.Register.
+type SessionStore interface {
+	// Load returns the values of the session with the given id. It returns an empty map if the session doesn't exist.
+	Load(r *http.Request, id string) (map[string]any, error)
+	// Save saves the values of the session with the given id. Headers set on w are not sent to the client
+	// for events received over a websocket connection.
+	Save(w http.ResponseWriter, r *http.Request, id string, values map[string]any) error
+}
+
+// WithSessionStore is an option to set the session store for the controller. The default store keeps the values in a cookie
+// encoded with the controller's secure cookie.
+func WithSessionStore(store SessionStore) ControllerOption {
+	return func(o *opt) {
+		o.sessionStore = store
+	}
+}
+
+// NewCookieSessionStore creates a session store which keeps the values in a cookie with the given name encoded by the given codecs.
+// The values set while handling events over a websocket connection can't be written to the cookie. They live as long as the connection,
+// so use the in-memory or redis store if they must survive a page load.
+func NewCookieSessionStore(name string, codecs ...securecookie.Codec) SessionStore {
+	return &cookieSessionStore{name: name, codecs: codecs}
+}
+
+type cookieSessionStore struct {
+	name   string
+	codecs []securecookie.Codec
+}
+
+type cookieSessionValues struct {
+	ID     string
+	Values userStore
+}
+
+func (s *cookieSessionStore) Load(r *http.Request, id string) (map[string]any, error) {
+	values := make(map[string]any)
+	cookie, err := r.Cookie(s.name)
+	if err != nil {
+		return values, nil
+	}
+	var data cookieSessionValues
+	if err := securecookie.DecodeMulti(s.name, cookie.Value, &data, s.codecs...); err != nil {
+		return values, err
+	}
+	// the cookie belongs to a previous session
+	if data.ID != id {
+		return values, nil
+	}
+	for k, v := range data.Values {
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (s *cookieSessionStore) Save(w http.ResponseWriter, r *http.Request, id string, values map[string]any) error {
+	encoded, err := securecookie.EncodeMulti(s.name, cookieSessionValues{ID: id, Values: values}, s.codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     s.name,
+		Value:    encoded,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return nil
+}
+
+// NewInmemSessionStore creates a session store which keeps the values in memory. Sessions expire after ttl without being saved.
+// Expired sessions are removed periodically by the controllers using the store until they are shut down.
+func NewInmemSessionStore(ttl time.Duration) SessionStore {
+	return &inmemSessionStore{ttl: ttl, janitorInterval: time.Minute, sessions: make(map[string]inmemSession)}
+}
+
+type inmemSession struct {
+	values  map[string]any
+	expires time.Time
+}
+
+type inmemSessionStore struct {
+	ttl time.Duration
+	// janitorInterval is how often expired sessions are removed
+	janitorInterval time.Duration
+	sessions        map[string]inmemSession
+	sync.RWMutex
+}
+
+// sessionJanitor is implemented by the session stores which remove expired sessions in the background.
+// The controller runs the janitor until Shutdown.
+type sessionJanitor interface {
+	runJanitor(quit <-chan struct{})
+}
+
+func (s *inmemSessionStore) runJanitor(quit <-chan struct{}) {
+	ticker := time.NewTicker(s.janitorInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-quit:
+			return
+		case <-ticker.C:
+			s.removeExpired()
+		}
+	}
+}
+
+func (s *inmemSessionStore) removeExpired() {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for id, session := range s.sessions {
+		if now.After(session.expires) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func (s *inmemSessionStore) Load(r *http.Request, id string) (map[string]any, error) {
+	s.RLock()
+	defer s.RUnlock()
+	values := make(map[string]any)
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.expires) {
+		return values, nil
+	}
+	for k, v := range session.values {
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (s *inmemSessionStore) Save(w http.ResponseWriter, r *http.Request, id string, values map[string]any) error {
+	copied := make(map[string]any)
+	for k, v := range values {
+		copied[k] = v
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.sessions[id] = inmemSession{values: copied, expires: time.Now().Add(s.ttl)}
+	return nil
+}
+
+// NewRedisSessionStore creates a session store which keeps the values in redis. Sessions expire after ttl without being saved.
+func NewRedisSessionStore(client *redis.Client, ttl time.Duration) SessionStore {
+	return &redisSessionStore{client: client, ttl: ttl}
+}
+
+type redisSessionStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func (s *redisSessionStore) key(id string) string {
+	return "fir:session:" + id
+}
+
+func (s *redisSessionStore) Load(r *http.Request, id string) (map[string]any, error) {
+	values := make(map[string]any)
+	data, err := s.client.Get(requestContext(r), s.key(id)).Bytes()
+	if err == redis.Nil {
+		return values, nil
+	}
+	if err != nil {
+		return values, err
+	}
+	var stored userStore
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&stored); err != nil {
+		return values, err
+	}
+	for k, v := range stored {
+		values[k] = v
+	}
+	return values, nil
+}
+
+func (s *redisSessionStore) Save(w http.ResponseWriter, r *http.Request, id string, values map[string]any) error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(userStore(values)); err != nil {
+		return err
+	}
+	return s.client.Set(requestContext(r), s.key(id), buf.Bytes(), s.ttl).Err()
+}
+
+func requestContext(r *http.Request) context.Context {
+	if r == nil {
+		return context.Background()
+	}
+	return r.Context()
+}
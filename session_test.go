@@ -0,0 +1,150 @@
+package fir
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	stores := map[string]SessionStore{
+		"cookie": nil,
+		"inmem":  NewInmemSessionStore(time.Minute),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			options := []ControllerOption{WithPublicDir(".")}
+			if store != nil {
+				options = append(options, WithSessionStore(store))
+			}
+			c := NewController("test", options...)
+			defer c.Shutdown(context.Background())
+			c.HandleFunc("/", func() RouteOptions {
+				return RouteOptions{
+					ID("visits"),
+					Content(`<p>{{.visits}}</p>`),
+					OnLoad(func(ctx RouteContext) error {
+						visits, _ := SessionValue[int](ctx.Session(), "visits")
+						visits++
+						ctx.Session().Set("visits", visits)
+						return ctx.KV("visits", visits)
+					}),
+				}
+			})
+			srv := httptest.NewServer(c)
+			defer srv.Close()
+
+			jar, _ := cookiejar.New(nil)
+			client := &http.Client{Jar: jar}
+			get := func() string {
+				resp, err := client.Get(srv.URL)
+				assert.NoError(t, err)
+				defer resp.Body.Close()
+				body, _ := io.ReadAll(resp.Body)
+				return string(body)
+			}
+			assert.Contains(t, get(), "<p>1</p>")
+			assert.Contains(t, get(), "<p>2</p>")
+			assert.Contains(t, get(), "<p>3</p>")
+
+			// a new browser session starts over
+			resp, err := http.Get(srv.URL)
+			assert.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			assert.Contains(t, string(body), "<p>1</p>")
+		})
+	}
+}
+
+func TestInmemSessionJanitor(t *testing.T) {
+	store := NewInmemSessionStore(time.Millisecond).(*inmemSessionStore)
+	store.janitorInterval = 10 * time.Millisecond
+	c := NewController("test", WithPublicDir("."), WithSessionStore(store))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	assert.NoError(t, store.Save(httptest.NewRecorder(), r, "a", map[string]any{"k": "v"}))
+
+	assert.Eventually(t, func() bool {
+		store.RLock()
+		defer store.RUnlock()
+		return len(store.sessions) == 0
+	}, time.Second, 10*time.Millisecond)
+
+	// the janitor stops on shutdown
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	assert.NoError(t, c.Shutdown(ctx))
+}
+
+func TestSessionAcrossPageLoadsAndWebsocketEvents(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithSessionStore(NewInmemSessionStore(time.Minute)))
+	defer c.Shutdown(context.Background())
+	bump := func(ctx RouteContext) error {
+		visits, _ := SessionValue[int](ctx.Session(), "visits")
+		visits++
+		ctx.Session().Set("visits", visits)
+		return ctx.KV("visits", visits)
+	}
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("visits"),
+			Content(`<p>{{.visits}}</p><div @fir:bump:ok::v="">{{block "v" .}}<b>{{.visits}}</b>{{end}}</div>`),
+			OnLoad(bump),
+			OnEvent("bump", bump),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Jar: jar}
+	load := func() string {
+		resp, err := client.Get(srv.URL)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+	page := load()
+	assert.Contains(t, page, "<p>1</p>")
+	match := sessionIDMeta.FindStringSubmatch(page)
+	if !assert.Len(t, match, 2) {
+		t.FailNow()
+	}
+
+	u, _ := url.Parse(srv.URL)
+	header := http.Header{}
+	for _, cookie := range jar.Cookies(u) {
+		header.Add("Cookie", cookie.String())
+	}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), header)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer conn.Close()
+	for !c.(*controller).pubsub.HasSubscribers(context.Background(), "anonymous:visits") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	bumpOverSocket := func() string {
+		assert.NoError(t, conn.WriteJSON(map[string]any{"event_id": "bump", "session_id": match[1]}))
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, message, err := conn.ReadMessage()
+		assert.NoError(t, err)
+		return string(message)
+	}
+
+	assert.Contains(t, bumpOverSocket(), `\u003cb\u003e2\u003c/b\u003e`)
+	assert.Contains(t, load(), "<p>3</p>")
+	// the socket sees the value set by the page load
+	assert.Contains(t, bumpOverSocket(), `\u003cb\u003e4\u003c/b\u003e`)
+	assert.Contains(t, load(), "<p>5</p>")
+}
@@ -0,0 +1,92 @@
+package fir
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/klog/v2"
+)
+
+var errShuttingDown = errors.New("server is shutting down")
+
+// Shutdown gracefully shuts down the controller. It stops the template watcher, closes every open websocket
+// connection with a close frame and waits for the connection goroutines to close their pubsub subscriptions and exit.
+// New websocket connections are refused once Shutdown is called. If ctx expires before the goroutines exit,
+// Shutdown returns the context's error.
+//
+// Shutdown doesn't stop the http server serving the controller. Call http.Server.Shutdown for that.
+func (c *controller) Shutdown(ctx context.Context) error {
+	c.Lock()
+	if !c.shuttingDown {
+		c.shuttingDown = true
+		close(c.quit)
+	}
+	conns := make([]*websocketConn, 0, len(c.conns))
+	for conn := range c.conns {
+		conns = append(conns, conn)
+	}
+	c.Unlock()
+
+	for _, conn := range conns {
+		conn.close(websocket.CloseGoingAway, errShuttingDown.Error())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		klog.Errorf("[Shutdown] error: %v, %d websocket connections didn't close in time\n", ctx.Err(), len(conns))
+		return ctx.Err()
+	}
+}
+
+// track registers a goroutine which Shutdown waits for. It returns false if the controller is shutting down.
+func (c *controller) track() bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.shuttingDown {
+		return false
+	}
+	c.workers.Add(1)
+	return true
+}
+
+// addConn registers an open websocket connection. It returns false if the controller started shutting down
+// after the connection was upgraded.
+func (c *controller) addConn(conn *websocketConn) bool {
+	c.Lock()
+	defer c.Unlock()
+	if c.shuttingDown {
+		return false
+	}
+	c.conns[conn] = struct{}{}
+	return true
+}
+
+func (c *controller) removeConn(conn *websocketConn) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.conns, conn)
+}
+
+// close sends a close frame to the client and closes the underlying connection.
+func (ws *websocketConn) close(code int, text string) {
+	ws.Lock()
+	defer ws.Unlock()
+	err := ws.conn.WriteControl(
+		websocket.CloseMessage,
+		websocket.FormatCloseMessage(code, text),
+		time.Now().Add(time.Second))
+	if err != nil {
+		klog.Warningf("[websocketConn] error writing close message: %v\n", err)
+	}
+	ws.conn.Close()
+}
@@ -0,0 +1,114 @@
+package fir
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livefir/fir/internal/dom"
+	"k8s.io/klog/v2"
+)
+
+// sseKeepAliveInterval is the interval at which a comment is written to an idle event stream
+// so that proxies don't close it.
+var sseKeepAliveInterval = 15 * time.Second
+
+func isSSERequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// onSSE streams the dom events rendered for server pushed events as Server-Sent Events.
+// Each message's data is the json encoded []dom.Event, the same payload which is written to a websocket connection.
+func onSSE(w http.ResponseWriter, r *http.Request, cntrl *controller) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	if !cntrl.track() {
+		http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer cntrl.workers.Done()
+	// the session cookie must be set before the headers are written
+	session := cntrl.session(w, r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	conn := &sseConn{w: w, flusher: flusher}
+	ctx := context.Background()
+	done := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	subscribeRoutes(ctx, r, w, cntrl, conn, newConnSessions(r, session.ID()), done, wg)
+
+	ticker := time.NewTicker(sseKeepAliveInterval)
+	defer ticker.Stop()
+loop:
+	for {
+		select {
+		case <-r.Context().Done():
+			break loop
+		case <-cntrl.quit:
+			break loop
+		case <-ticker.C:
+			if err := conn.keepAlive(); err != nil {
+				break loop
+			}
+		}
+	}
+	close(done)
+	wg.Wait()
+	conn.close()
+}
+
+type sseConn struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	closed  bool
+	sync.Mutex
+}
+
+func (s *sseConn) writeEvents(events []dom.Event) error {
+	s.Lock()
+	defer s.Unlock()
+	if s.closed {
+		return fmt.Errorf("event stream is closed")
+	}
+	eventsData, err := json.Marshal(events)
+	if err != nil {
+		klog.Errorf("[sseConn] error: marshaling events %+v, err %v", events, err)
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", eventsData); err != nil {
+		klog.Errorf("[sseConn] error: writing events, err %v", err)
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseConn) keepAlive() error {
+	s.Lock()
+	defer s.Unlock()
+	if _, err := fmt.Fprint(s.w, ": keep-alive\n\n"); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+// close prevents writes after the handler returns since the response writer can't be used anymore
+func (s *sseConn) close() {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+}
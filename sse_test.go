@@ -0,0 +1,69 @@
+package fir
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSE(t *testing.T) {
+	c := NewController("test", WithPublicDir("."), WithTransport(SSE))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content(`<div @fir:inc:ok::count="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			OnEvent("inc", func(ctx RouteContext) error { return nil }),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	// websocket is disabled for the sse transport
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	ps := c.(*controller).pubsub
+	for !ps.HasSubscribers(ctx, "anonymous:counter") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	id := "inc"
+	err = ps.Publish(ctx, "anonymous:counter", pubsub.Event{
+		ID:     &id,
+		State:  eventstate.OK,
+		Detail: map[string]any{"count": 1},
+	})
+	assert.NoError(t, err)
+
+	scanner := bufio.NewScanner(resp.Body)
+	var data string
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "data: ") {
+			data = strings.TrimPrefix(scanner.Text(), "data: ")
+			break
+		}
+	}
+	assert.Contains(t, data, `"type":"fir:inc:ok::count"`)
+	assert.Contains(t, data, `\u003cspan\u003e1\u003c/span\u003e`)
+}
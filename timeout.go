@@ -0,0 +1,47 @@
+package fir
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// WithEventTimeout is an option to set the default timeout of the route handlers. The context returned by
+// RouteContext.Context is cancelled when the timeout expires. Default is no timeout.
+func WithEventTimeout(timeout time.Duration) ControllerOption {
+	return func(o *opt) {
+		o.eventTimeout = timeout
+	}
+}
+
+// EventTimeout sets the timeout of the route's handlers, overriding the controller's WithEventTimeout.
+// If event names are given, the timeout applies to those onEvent handlers only and overrides the route's timeout.
+func EventTimeout(timeout time.Duration, events ...string) RouteOption {
+	return func(opt *routeOpt) {
+		if len(events) == 0 {
+			opt.timeout = timeout
+			return
+		}
+		if opt.eventTimeouts == nil {
+			opt.eventTimeouts = make(map[string]time.Duration)
+		}
+		for _, event := range events {
+			opt.eventTimeouts[strings.ToLower(event)] = timeout
+		}
+	}
+}
+
+// handlerContext returns the context for the handler of the event bounded by the event's, route's or controller's timeout
+func (rt *route) handlerContext(parent context.Context, eventID string) (context.Context, context.CancelFunc) {
+	timeout := rt.eventTimeout
+	if rt.timeout > 0 {
+		timeout = rt.timeout
+	}
+	if eventTimeout, ok := rt.eventTimeouts[strings.ToLower(eventID)]; ok {
+		timeout = eventTimeout
+	}
+	if timeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, timeout)
+}
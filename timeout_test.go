@@ -0,0 +1,62 @@
+package fir
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventContext(t *testing.T) {
+	handlerErrs := make(chan error, 1)
+	started := make(chan struct{}, 1)
+	c := NewController("test", WithPublicDir("."), WithEventTimeout(time.Minute))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("reports"),
+			Content(`<div @fir:slow:ok="" @fir:wait:ok="">reports</div>`),
+			EventTimeout(50*time.Millisecond, "slow"),
+			OnEvent("slow", func(ctx RouteContext) error {
+				<-ctx.Context().Done()
+				handlerErrs <- ctx.Context().Err()
+				return ctx.Context().Err()
+			}),
+			OnEvent("wait", func(ctx RouteContext) error {
+				_, hasDeadline := ctx.Context().Deadline()
+				assert.True(t, hasDeadline)
+				started <- struct{}{}
+				<-ctx.Context().Done()
+				handlerErrs <- ctx.Context().Err()
+				return nil
+			}),
+		}
+	})
+
+	// the event's timeout
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"event_id":"slow"}`))
+	r.Header.Set("X-FIR-MODE", "event")
+	c.ServeHTTP(httptest.NewRecorder(), r)
+	assert.True(t, errors.Is(<-handlerErrs, context.DeadlineExceeded))
+
+	// closing the websocket connection cancels the running handler
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+	sessionID := getPageSessionID(t, srv.URL)
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+	assert.NoError(t, conn.WriteJSON(map[string]any{"event_id": "wait", "session_id": sessionID}))
+	<-started
+	conn.Close()
+	select {
+	case err := <-handlerErrs:
+		assert.True(t, errors.Is(err, context.Canceled))
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler context was not cancelled after the connection closed")
+	}
+}
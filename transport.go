@@ -0,0 +1,166 @@
+package fir
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/livefir/fir/internal/dom"
+	"github.com/livefir/fir/pubsub"
+	"k8s.io/klog/v2"
+)
+
+// Transport is the transport used to push server events to the client.
+type Transport string
+
+const (
+	// Websocket pushes server events and receives client events over a websocket connection. This is the default.
+	Websocket Transport = "websocket"
+	// SSE pushes server events over a text/event-stream(Server-Sent Events) connection.
+	// Client events are sent as X-FIR-MODE: event POST requests.
+	SSE Transport = "sse"
+	// LongPoll pushes server events as responses to long polling requests. It is the last resort when neither
+	// websockets nor Server-Sent Events work. Client events are sent as X-FIR-MODE: event POST requests.
+	LongPoll Transport = "longpoll"
+)
+
+// WithTransport is an option to set the transport used to push server events to the client.
+// The websocket endpoint is disabled when the transport is not Websocket. The sse and long polling endpoints
+// are always enabled since the client falls back to them when a websocket connection can't be established:
+// websocket -> sse -> long polling.
+func WithTransport(transport Transport) ControllerOption {
+	return func(o *opt) {
+		o.transport = transport
+	}
+}
+
+// eventConn is a client connection to which the dom events rendered for server pushed events are written.
+type eventConn interface {
+	writeEvents(events []dom.Event) error
+}
+
+// subscribeRoutes subscribes the connection to the channels of all the controller's routes. The dom events rendered
+// for the events published on the channels are written to the connection until done is closed. Error events are
+// written only if they belong to one of the connection's page sessions. It returns once the channels are subscribed
+// so that no event published afterwards is missed. wg is done when all the subscriptions are closed.
+//
+// The events are rendered and written one at a time in the order they are received, so that a client shows the result
+// of the latest event of a channel.
+func subscribeRoutes(ctx context.Context, r *http.Request, w http.ResponseWriter, cntrl *controller, conn eventConn, sessions *connSessions, done <-chan struct{}, wg *sync.WaitGroup) {
+	wg.Add(len(cntrl.routes))
+	subscribed := &sync.WaitGroup{}
+	subscribed.Add(len(cntrl.routes))
+
+	writes := make(chan func(), eventWriteQueueSize)
+	go func() {
+		for {
+			select {
+			case write := <-writes:
+				write()
+			case <-done:
+				return
+			}
+		}
+	}()
+	enqueue := func(write func()) {
+		select {
+		case writes <- write:
+		case <-done:
+		}
+	}
+
+	for _, rt := range cntrl.routes {
+		go func(route *route) {
+			defer wg.Done()
+			var once sync.Once
+			ready := func() { once.Do(subscribed.Done) }
+			defer ready()
+			routeChannel := route.channelFunc(r, route.id)
+			if routeChannel == nil {
+				klog.Errorf("[subscribeRoutes] error: channel is empty")
+				return
+			}
+
+			// subscribers: the user's channel and the channels of the publish targets. see publish.go
+			channels := append([]string{*routeChannel}, route.targetChannels(r, sessions.sessionID)...)
+			delivered := newDeliveredEvents()
+			for _, channel := range channels {
+				subscription, err := route.pubsub.Subscribe(ctx, channel)
+				if err != nil {
+					klog.Errorf("[subscribeRoutes] error: subscribing to channel %s, %v", channel, err)
+					return
+				}
+				defer subscription.Close()
+
+				go func(channel string) {
+					for pubsubEvent := range subscription.C() {
+						if !sessions.accepts(pubsubEvent) || !delivered.first(pubsubEvent) {
+							continue
+						}
+						routeCtx := RouteContext{
+							request:  r,
+							response: w,
+							route:    route,
+						}
+						pubsubEvent := pubsubEvent
+						enqueue(func() { renderAndWriteEvent(conn, channel, routeCtx, pubsubEvent) })
+					}
+				}(channel)
+			}
+
+			if route.developmentMode {
+				// subscriber for reload operations in development mode. see watch.go
+				reloadSubscriber, err := route.pubsub.Subscribe(ctx, devReloadChannel)
+				if err != nil {
+					klog.Errorf("[subscribeRoutes] error: subscribing to channel %s, %v", devReloadChannel, err)
+					return
+				}
+				defer reloadSubscriber.Close()
+
+				go func() {
+					for pubsubEvent := range reloadSubscriber.C() {
+						pubsubEvent := pubsubEvent
+						enqueue(func() { writeEvent(conn, pubsubEvent) })
+					}
+				}()
+			}
+			ready()
+			<-done
+		}(rt)
+	}
+	subscribed.Wait()
+}
+
+// eventWriteQueueSize is the number of received events waiting to be written to a connection before
+// the subscriptions wait for the connection
+const eventWriteQueueSize = 64
+
+// detachRequest returns a copy of the request which can be used after its handler returned, e.g. to render the events
+// of a long polling session. It keeps the url, headers, user and path params but not the request's cancellation.
+func detachRequest(r *http.Request) *http.Request {
+	ctx := context.Background()
+	for _, key := range []ContextKey{UserKey, PathParamsKey} {
+		if v := r.Context().Value(key); v != nil {
+			ctx = context.WithValue(ctx, key, v)
+		}
+	}
+	detached := r.Clone(ctx)
+	detached.Body = http.NoBody
+	return detached
+}
+
+func renderAndWriteEvent(conn eventConn, channel string, ctx RouteContext, pubsubEvent pubsub.Event) error {
+	events := renderDOMEvents(ctx, pubsubEvent)
+	if len(events) == 0 {
+		err := fmt.Errorf("[writeDOMevents] error: message is empty, channel %s, events %+v", channel, pubsubEvent)
+		log.Println(err)
+		return err
+	}
+	return conn.writeEvents(events)
+}
+
+func writeEvent(conn eventConn, pubsubEvent pubsub.Event) error {
+	return conn.writeEvents([]dom.Event{{Type: pubsubEvent.ID}})
+}
@@ -0,0 +1,65 @@
+package fir
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counterParams struct {
+	By int `json:"by" validate:"gte=1"`
+}
+
+type counterQuery struct {
+	Name string `json:"name" validate:"required"`
+}
+
+func TestOnEventOf(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content(`<div @fir:inc:ok::count="" @fir:inc:error="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			OnEventOf("inc", func(ctx RouteContext, params counterParams) error {
+				return ctx.KV("count", params.By)
+			}),
+			OnLoadOf(func(ctx RouteContext, params counterQuery) error {
+				return ctx.KV("count", params.Name)
+			}),
+		}
+	})
+
+	postEvent := func(params string) string {
+		body, err := json.Marshal(map[string]any{"event_id": "inc", "params": json.RawMessage(params)})
+		assert.NoError(t, err)
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(string(body)))
+		r.Header.Set("X-FIR-MODE", "event")
+		w := httptest.NewRecorder()
+		c.ServeHTTP(w, r)
+		return w.Body.String()
+	}
+
+	assert.Contains(t, postEvent(`{"by":3}`), `\u003cspan\u003e3\u003c/span\u003e`)
+	assert.Contains(t, postEvent(`{"by":0}`), "by must be greater than or equal to 1")
+	assert.Contains(t, postEvent(`{"by":"three"}`), "by must be a valid integer")
+
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?name=visitor", nil))
+	assert.Contains(t, w.Body.String(), "<span>visitor</span>")
+
+	// binding errors of onLoad are looked up by {{.fir.Error "onload.field"}}
+	c.HandleFunc("/errors", func() RouteOptions {
+		return RouteOptions{
+			ID("errors"),
+			Content(`<p>{{.fir.Error "onload.name"}}</p>`),
+			OnLoadOf(func(ctx RouteContext, params counterQuery) error { return nil }),
+		}
+	})
+	w = httptest.NewRecorder()
+	c.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/errors", nil))
+	assert.Contains(t, w.Body.String(), "<p>name is required</p>")
+}
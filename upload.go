@@ -0,0 +1,128 @@
+package fir
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	// firEventField is the multipart form field which carries the json encoded event of an event request with file uploads.
+	firEventField = "_fir_event"
+	// defaultMaxUploadSize is the default maximum size of a multipart request body.
+	defaultMaxUploadSize int64 = 32 << 20
+)
+
+// multipartMemory is the maximum size of the uploaded files kept in memory. Larger files are stored in temporary files
+// which are removed once the request is handled.
+var multipartMemory int64 = 32 << 20
+
+// WithMaxUploadSize is an option to set the maximum size in bytes of a multipart(file upload) or json api request body. Default is 32MB.
+// Larger requests are rejected with 413 Request Entity Too Large.
+func WithMaxUploadSize(size int64) ControllerOption {
+	return func(o *opt) {
+		o.maxUploadSize = size
+	}
+}
+
+func isMultipartRequest(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// parseForm parses the form values and, for multipart requests, the uploaded files of a request body limited to maxSize.
+// It returns the http status code to respond with on error.
+func parseForm(w http.ResponseWriter, r *http.Request, maxSize int64) (int, error) {
+	if !isMultipartRequest(r) {
+		if err := r.ParseForm(); err != nil {
+			return http.StatusBadRequest, err
+		}
+		return http.StatusOK, nil
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+	if err := r.ParseMultipartForm(multipartMemory); err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return http.StatusRequestEntityTooLarge, fmt.Errorf("request body is larger than %d bytes", maxSize)
+		}
+		return http.StatusBadRequest, err
+	}
+	return http.StatusOK, nil
+}
+
+// removeMultipartForm removes the temporary files of the uploads parsed from the request
+func removeMultipartForm(r *http.Request) {
+	if r.MultipartForm == nil {
+		return
+	}
+	if err := r.MultipartForm.RemoveAll(); err != nil {
+		klog.Warningf("[removeMultipartForm] error removing uploaded files: %v\n", err)
+	}
+}
+
+// FormFile returns the first file uploaded for the form field. http.ErrMissingFile is returned if there is none.
+func (c RouteContext) FormFile(name string) (*multipart.FileHeader, error) {
+	files := c.FormFiles(name)
+	if len(files) == 0 {
+		return nil, http.ErrMissingFile
+	}
+	return files[0], nil
+}
+
+// FormFiles returns the files uploaded for the form field
+func (c RouteContext) FormFiles(name string) []*multipart.FileHeader {
+	if c.request == nil || c.request.MultipartForm == nil {
+		return nil
+	}
+	return c.request.MultipartForm.File[name]
+}
+
+var (
+	fileHeaderType  = reflect.TypeOf(&multipart.FileHeader{})
+	fileHeadersType = reflect.TypeOf([]*multipart.FileHeader{})
+)
+
+// bindFiles sets the *multipart.FileHeader and []*multipart.FileHeader fields of the struct to the files uploaded
+// for the form field of the same json name.
+func bindFiles(v any, form *multipart.Form) error {
+	if form == nil || len(form.File) == 0 {
+		return nil
+	}
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	val = val.Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Type != fileHeaderType && field.Type != fileHeadersType {
+			continue
+		}
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		files := form.File[name]
+		if len(files) == 0 {
+			continue
+		}
+		if field.Type == fileHeaderType {
+			val.Field(i).Set(reflect.ValueOf(files[0]))
+		} else {
+			val.Field(i).Set(reflect.ValueOf(files))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package fir
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type uploadReq struct {
+	Title       string                  `json:"title"`
+	Attachment  *multipart.FileHeader   `json:"attachment"`
+	Attachments []*multipart.FileHeader `json:"attachments"`
+}
+
+func multipartBody(t *testing.T, values map[string]string, files map[string][]string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range values {
+		assert.NoError(t, mw.WriteField(k, v))
+	}
+	for field, contents := range files {
+		for _, content := range contents {
+			fw, err := mw.CreateFormFile(field, field+".txt")
+			assert.NoError(t, err)
+			fw.Write([]byte(content))
+		}
+	}
+	assert.NoError(t, mw.Close())
+	return body, mw.FormDataContentType()
+}
+
+func uploadController(t *testing.T, received chan uploadReq, options ...ControllerOption) Controller {
+	c := NewController("test", append([]ControllerOption{WithPublicDir(".")}, options...)...)
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("upload"),
+			Content(`<div @fir:upload:ok::done="">{{block "done" .}}{{.title}}{{end}}</div>`),
+			OnEvent("upload", func(ctx RouteContext) error {
+				var req uploadReq
+				if err := ctx.Bind(&req); err != nil {
+					return err
+				}
+				file, err := ctx.FormFile("attachment")
+				assert.NoError(t, err)
+				assert.Equal(t, req.Attachment, file)
+				received <- req
+				return ctx.KV("title", req.Title)
+			}),
+		}
+	})
+	return c
+}
+
+func readUploadedFile(t *testing.T, fh *multipart.FileHeader) string {
+	f, err := fh.Open()
+	assert.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestFormPostFileUpload(t *testing.T) {
+	received := make(chan uploadReq, 1)
+	c := uploadController(t, received)
+
+	body, contentType := multipartBody(t,
+		map[string]string{"title": "report"},
+		map[string][]string{"attachment": {"hello"}, "attachments": {"a", "b"}})
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	req := <-received
+	assert.Equal(t, "report", req.Title)
+	assert.Equal(t, "hello", readUploadedFile(t, req.Attachment))
+	if assert.Len(t, req.Attachments, 2) {
+		assert.Equal(t, "b", readUploadedFile(t, req.Attachments[1]))
+	}
+}
+
+func TestEventFileUpload(t *testing.T) {
+	received := make(chan uploadReq, 1)
+	c := uploadController(t, received)
+
+	event, err := json.Marshal(map[string]any{
+		"event_id": "upload",
+		"is_form":  true,
+		"params":   map[string][]string{"title": {"report"}},
+	})
+	assert.NoError(t, err)
+	body, contentType := multipartBody(t,
+		map[string]string{firEventField: string(event)},
+		map[string][]string{"attachment": {"hello"}})
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", contentType)
+	r.Header.Set("X-FIR-MODE", "event")
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "fir:upload:ok::done")
+
+	req := <-received
+	assert.Equal(t, "report", req.Title)
+	assert.Equal(t, "hello", readUploadedFile(t, req.Attachment))
+}
+
+func TestFileUploadSizeLimit(t *testing.T) {
+	c := uploadController(t, make(chan uploadReq, 1), WithMaxUploadSize(1024))
+
+	body, contentType := multipartBody(t, nil, map[string][]string{"attachment": {string(bytes.Repeat([]byte("a"), 2048))}})
+	r := httptest.NewRequest(http.MethodPost, "/", body)
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUploadTempFilesRemoved(t *testing.T) {
+	defer func(memory int64) { multipartMemory = memory }(multipartMemory)
+	multipartMemory = 1024
+
+	var tempFile string
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("upload"),
+			Content(`<div @fir:upload:ok="">upload</div>`),
+			OnEvent("upload", func(ctx RouteContext) error {
+				fh, err := ctx.FormFile("attachment")
+				if err != nil {
+					return err
+				}
+				f, err := fh.Open()
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				// the upload is larger than the memory limit so it is stored in a temporary file
+				if osFile, ok := f.(*os.File); ok {
+					tempFile = osFile.Name()
+				}
+				return nil
+			}),
+		}
+	})
+
+	body, contentType := multipartBody(t, nil, map[string][]string{"attachment": {strings.Repeat("a", 4096)}})
+	r := httptest.NewRequest(http.MethodPost, "/?event=upload", body)
+	r.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	c.ServeHTTP(w, r)
+	assert.Equal(t, http.StatusFound, w.Code)
+
+	if assert.NotEmpty(t, tempFile) {
+		_, err := os.Stat(tempFile)
+		assert.True(t, os.IsNotExist(err))
+	}
+}
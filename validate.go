@@ -0,0 +1,179 @@
+package fir
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/schema"
+	firErrors "github.com/livefir/fir/internal/errors"
+)
+
+// WithValidator is an option to set the validator used by RouteContext.Validate and RouteContext.BindAndValidate.
+// The default validator names fields by their json tag.
+func WithValidator(v *validator.Validate) ControllerOption {
+	return func(o *opt) {
+		o.validator = v
+	}
+}
+
+func newValidator() *validator.Validate {
+	validate := validator.New()
+	// register function to get tag name from json tags.
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name := strings.SplitN(fld.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+	return validate
+}
+
+// BindAndValidate binds the path, query and event params into the given struct and validates it using its `validate` tags.
+// Values which can't be converted to the type of their field are returned as field errors like validation failures. See Validate.
+func (c RouteContext) BindAndValidate(v any) error {
+	if err := c.Bind(v); err != nil {
+		return bindFieldErrors(err)
+	}
+	return c.Validate(v)
+}
+
+// bindFieldErrors converts the form and json decoding errors of a field into field errors keyed by the field's json path e.g. owner.email.
+// Other errors are returned as is.
+func bindFieldErrors(err error) error {
+	var multiError schema.MultiError
+	if errors.As(err, &multiError) {
+		fields := firErrors.Fields{}
+		for _, keyErr := range multiError {
+			var conversionError schema.ConversionError
+			if !errors.As(keyErr, &conversionError) {
+				return err
+			}
+			fields[conversionError.Key] = fmt.Errorf("%s must be a valid %s",
+				lastPathSegment(conversionError.Key), typeName(conversionError.Type))
+		}
+		return &fields
+	}
+	var typeError *json.UnmarshalTypeError
+	if errors.As(err, &typeError) && typeError.Field != "" {
+		return &firErrors.Fields{typeError.Field: fmt.Errorf("%s must be a valid %s",
+			lastPathSegment(typeError.Field), typeName(typeError.Type))}
+	}
+	return err
+}
+
+func lastPathSegment(path string) string {
+	return path[strings.LastIndex(path, ".")+1:]
+}
+
+func typeName(t reflect.Type) string {
+	if t == nil {
+		return "value"
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	// named types like uuid.UUID or enums
+	if t.PkgPath() != "" {
+		return "value"
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	}
+	return "value"
+}
+
+// Validate validates the struct using its `validate` tags. Validation failures are returned as field errors
+// keyed by the json name of the field so that they can be looked up by {{.fir.Error "myevent.field"}}.
+// Nested struct fields are keyed by their json path e.g. {{.fir.Error "myevent.owner.email"}}.
+func (c RouteContext) Validate(v any) error {
+	err := c.route.validator.Struct(v)
+	if err == nil {
+		return nil
+	}
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
+		return err
+	}
+	fields := firErrors.Fields{}
+	for _, fieldError := range validationErrors {
+		field := fieldPath(fieldError)
+		if _, ok := fields[field]; ok {
+			continue
+		}
+		fields[field] = errors.New(validationMessage(fieldError))
+	}
+	return &fields
+}
+
+// fieldPath returns the json path of the field without the name of the validated struct e.g. owner.email for createReq.owner.email
+func fieldPath(fieldError validator.FieldError) string {
+	namespace := fieldError.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+func validationMessage(fieldError validator.FieldError) string {
+	field := fieldError.Field()
+	param := fieldError.Param()
+	unit := ""
+	switch fieldError.Kind() {
+	case reflect.String:
+		unit = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = " items"
+	}
+	switch fieldError.Tag() {
+	case "required", "required_if", "required_unless", "required_with", "required_without":
+		return fmt.Sprintf("%s is required", field)
+	case "email":
+		return fmt.Sprintf("%s must be a valid email address", field)
+	case "url", "http_url":
+		return fmt.Sprintf("%s must be a valid url", field)
+	case "uuid", "uuid4":
+		return fmt.Sprintf("%s must be a valid uuid", field)
+	case "oneof":
+		return fmt.Sprintf("%s must be one of %s", field, strings.Join(strings.Fields(param), ", "))
+	case "len":
+		return fmt.Sprintf("%s must be %s%s long", field, param, unit)
+	case "min":
+		if unit == "" {
+			return fmt.Sprintf("%s must be %s or greater", field, param)
+		}
+		return fmt.Sprintf("%s must be at least %s%s long", field, param, unit)
+	case "max":
+		if unit == "" {
+			return fmt.Sprintf("%s must be %s or less", field, param)
+		}
+		return fmt.Sprintf("%s must be at most %s%s long", field, param, unit)
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, param)
+	case "gte":
+		return fmt.Sprintf("%s must be greater than or equal to %s", field, param)
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, param)
+	case "lte":
+		return fmt.Sprintf("%s must be less than or equal to %s", field, param)
+	case "eqfield":
+		return fmt.Sprintf("%s must be equal to %s", field, param)
+	case "alphanum":
+		return fmt.Sprintf("%s must contain only letters and numbers", field)
+	case "numeric", "number":
+		return fmt.Sprintf("%s must be a number", field)
+	}
+	return fmt.Sprintf("%s is invalid(%s)", field, fieldError.Tag())
+}
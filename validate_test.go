@@ -0,0 +1,66 @@
+package fir
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	firErrors "github.com/livefir/fir/internal/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+type createTodoReq struct {
+	Title    string   `json:"title" validate:"required,min=3"`
+	Priority int      `json:"priority" validate:"gte=1,lte=5"`
+	Tags     []string `json:"tags" validate:"max=2"`
+	Owner    struct {
+		Email string `json:"email" validate:"required,email"`
+	} `json:"owner"`
+	Reviewer struct {
+		Email string `json:"email" validate:"omitempty,email"`
+	} `json:"reviewer"`
+}
+
+func TestBindAndValidate(t *testing.T) {
+	c := NewController("test", WithPublicDir(".")).(*controller)
+	rt := &route{cntrl: c, routeOpt: routeOpt{opt: c.opt}}
+	newCtx := func(params string) RouteContext {
+		return RouteContext{
+			event:   Event{ID: "create", Params: json.RawMessage(params)},
+			request: httptest.NewRequest("POST", "/", nil),
+			route:   rt,
+		}
+	}
+
+	var req createTodoReq
+	err := newCtx(`{"title":"ab","priority":7,"tags":["a","b","c"],"owner":{"email":"nope"},"reviewer":{"email":"nah"}}`).BindAndValidate(&req)
+	fields, ok := err.(*firErrors.Fields)
+	if !assert.True(t, ok, "expected field errors, got %v", err) {
+		return
+	}
+	assert.Equal(t, map[string]string{
+		"title":          "title must be at least 3 characters long",
+		"priority":       "priority must be less than or equal to 5",
+		"tags":           "tags must be at most 2 items long",
+		"owner.email":    "email must be a valid email address",
+		"reviewer.email": "email must be a valid email address",
+	}, fields.Map())
+
+	// nested field errors are looked up by their json path
+	fir := &RouteDOMContext{errors: map[string]any{"create": fields.Map()}}
+	assert.Equal(t, "email must be a valid email address", fir.Error("create.owner.email"))
+	assert.Equal(t, "email must be a valid email address", fir.Error("create", "reviewer.email"))
+	assert.Nil(t, fir.Error("create.owner"))
+
+	req = createTodoReq{}
+	err = newCtx(`{"title":"abc","priority":1,"owner":{"email":5}}`).BindAndValidate(&req)
+	fields, ok = err.(*firErrors.Fields)
+	if assert.True(t, ok, "expected field errors, got %v", err) {
+		assert.Equal(t, map[string]string{"owner.email": "email must be a valid string"}, fields.Map())
+	}
+
+	req = createTodoReq{}
+	err = newCtx(`{"title":"abc","priority":1,"owner":{"email":"a@b.co"}}`).BindAndValidate(&req)
+	assert.NoError(t, err)
+	assert.Equal(t, "abc", req.Title)
+}
@@ -19,13 +19,14 @@ var defaultWatchExtensions = []string{".gohtml", ".gotmpl", ".html", ".tmpl"}
 
 const devReloadChannel = "dev_reload"
 
+// watchTemplates watches the template files for changes until the controller is shut down
 func watchTemplates(wc *controller) {
+	defer wc.workers.Done()
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer watcher.Close()
-	done := make(chan bool)
 
 	go func() {
 		for {
@@ -64,5 +65,5 @@ func watchTemplates(wc *controller) {
 		return nil
 	})
 
-	<-done
+	<-wc.quit
 }
@@ -12,90 +12,74 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/livefir/fir/internal/dom"
-	"github.com/livefir/fir/pubsub"
 	"k8s.io/klog/v2"
 )
 
 func onWebsocket(w http.ResponseWriter, r *http.Request, cntrl *controller) {
+	if !cntrl.track() {
+		http.Error(w, errShuttingDown.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	defer cntrl.workers.Done()
+	// the browser session of the connection. Its values are loaded for each event since page loads, form posts
+	// and other tabs can change them while the connection is open.
+	sessionID := cntrl.session(w, r).ID()
 	conn, err := cntrl.websocketUpgrader.Upgrade(w, r, nil)
 	if err != nil {
+		// the client falls back to sse or long polling
+		klog.Warningf("[onWebsocket] websocket upgrade failed: %v\n", err)
 		return
 	}
 	defer conn.Close()
 	wsConn := &websocketConn{conn: conn}
+	if !cntrl.addConn(wsConn) {
+		wsConn.close(websocket.CloseGoingAway, "server is shutting down")
+		return
+	}
+	defer cntrl.removeConn(wsConn)
 	ctx := context.Background()
 	done := make(chan struct{})
 	wg := &sync.WaitGroup{}
-	wg.Add(len(cntrl.routes))
-
-	for _, rt := range cntrl.routes {
-		go func(route *route) {
-			defer wg.Done()
-			routeChannel := route.channelFunc(r, route.id)
-			if routeChannel == nil {
-				klog.Errorf("[onWebsocket] error: channel is empty")
-				http.Error(w, "channel is empty", http.StatusUnauthorized)
-				return
-			}
-
-			// subscribers
-			subscription, err := route.pubsub.Subscribe(ctx, *routeChannel)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
+	// the pages of all the routes and tabs which send events over the connection
+	sessions := newConnSessions(r, sessionID)
+	subscribeRoutes(ctx, r, w, cntrl, wsConn, sessions, done, wg)
+
+	// the events are handled one at a time in the order they are received while the connection keeps being read,
+	// so that the context of a running handler is cancelled as soon as the connection is closed.
+	connCtx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	events := make(chan websocketEvent, websocketEventQueueSize)
+	handlerDone := make(chan struct{})
+	go func() {
+		defer close(handlerDone)
+		for ev := range events {
+			if connCtx.Err() != nil {
+				// the connection is closed
+				continue
 			}
-			defer subscription.Close()
-
-			go func() {
-				for pubsubEvent := range subscription.C() {
-					routeCtx := RouteContext{
-						request:  r,
-						response: w,
-						route:    route,
-					}
-					go renderAndWriteEvent(wsConn, *routeChannel, routeCtx, pubsubEvent)
+			// pass the event through the route's middlewares so that they can reject or decorate it
+			eventWriter := newDiscardResponseWriter()
+			ev.route.group.wrap(http.HandlerFunc(func(_ http.ResponseWriter, eventRequest *http.Request) {
+				eventCtx := RouteContext{
+					event:    ev.event,
+					request:  eventRequest,
+					response: w,
+					route:    ev.route,
+					session:  newSession(cntrl.sessionStore, eventRequest, sessionID),
+					pending:  newPendingEvents(),
+					targets:  newPublishTargets(),
 				}
-			}()
-
-			// eventSender
-			go func() {
-				for event := range route.eventSender {
-					eventCtx := RouteContext{
-						event:    event,
-						request:  r,
-						response: w,
-						route:    route,
-					}
-					klog.Errorf("[onWebsocket] received server event: %+v\n", event)
-					onEventFunc, ok := route.onEvents[strings.ToLower(event.ID)]
-					if !ok {
-						klog.Errorf("[onWebsocket] err: event %v, event.id not found\n", event)
-						continue
-					}
-
-					// ignore user store for server events
-					handleOnEventResult(onEventFunc(eventCtx), eventCtx, publishEvents(ctx, eventCtx))
-				}
-			}()
-
-			if route.developmentMode {
-				// subscriber for reload operations in development mode. see watch.go
-				reloadSubscriber, err := route.pubsub.Subscribe(ctx, devReloadChannel)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
+				// publishing is not bound to the connection since the event is delivered to the other subscribers too
+				handleOnEventResult(eventCtx.call(ev.onEvent), eventCtx, publishEvents(ctx, eventCtx))
+				if events := eventCtx.pending.take(); len(events) > 0 {
+					wsConn.writeEvents(events)
 				}
-				defer reloadSubscriber.Close()
-
-				go func() {
-					for pubsubEvent := range reloadSubscriber.C() {
-						go writeEvent(wsConn, pubsubEvent)
-					}
-				}()
+			})).ServeHTTP(eventWriter, r.Clone(connCtx))
+			if eventWriter.status != 0 && eventWriter.status != http.StatusOK {
+				klog.Errorf("[onWebsocket] event %v rejected by middleware with status %d\n", ev.event.ID, eventWriter.status)
 			}
-			<-done
-		}(rt)
-	}
+		}
+	}()
 
 loop:
 	for {
@@ -122,20 +106,12 @@ loop:
 			continue
 		}
 
-		// var routeID string
-		// if err = cntrl.secureCookie.Decode(cntrl.cookieName, *event.SessionID, &routeID); err != nil {
-		// 	klog.Errorf("[onWebsocket] err: event %v, cookie decode error: %v\n", event, err)
-		// 	continue
-		// }
-
-		eventRoute := cntrl.routes[*event.SessionID]
-
-		eventCtx := RouteContext{
-			event:    event,
-			request:  r,
-			response: w,
-			route:    eventRoute,
+		eventRoute, err := cntrl.routeFromSessionID(*event.SessionID)
+		if err != nil {
+			klog.Errorf("[onWebsocket] err: event %v rejected, %v\n", event.ID, err)
+			continue
 		}
+		sessions.add(*event.SessionID)
 
 		klog.Errorf("[onWebsocket] route %v received event: %+v\n", eventRoute.id, event)
 		onEventFunc, ok := eventRoute.onEvents[strings.ToLower(event.ID)]
@@ -143,56 +119,59 @@ loop:
 			klog.Errorf("[onWebsocket] err: event %v, event.id not found\n", event)
 			continue
 		}
-
-		handleOnEventResult(onEventFunc(eventCtx), eventCtx, publishEvents(ctx, eventCtx))
+		events <- websocketEvent{event: event, route: eventRoute, onEvent: onEventFunc}
 	}
+	// cancel the running handler and drop the queued events
+	cancel()
+	close(events)
+	<-handlerDone
 	close(done)
 	wg.Wait()
 }
 
+// websocketEventQueueSize is the number of events received over a websocket connection which can wait for the running handler.
+// Reading from the connection blocks when the queue is full.
+const websocketEventQueueSize = 16
+
+type websocketEvent struct {
+	event   Event
+	route   *route
+	onEvent OnEventFunc
+}
+
 type websocketConn struct {
 	conn *websocket.Conn
 	sync.Mutex
 }
 
-func renderAndWriteEvent(ws *websocketConn, channel string, ctx RouteContext, pubsubEvent pubsub.Event) error {
+func (ws *websocketConn) writeEvents(events []dom.Event) error {
 	ws.Lock()
 	defer ws.Unlock()
-	events := renderDOMEvents(ctx, pubsubEvent)
 	eventsData, err := json.Marshal(events)
 	if err != nil {
 		klog.Errorf("[writeDOMevents] error: marshaling events %+v, err %v", events, err)
 		return err
 	}
-	if len(eventsData) == 0 {
-		err := fmt.Errorf("[writeDOMevents] error: message is empty, channel %s, events %+v", channel, pubsubEvent)
-		log.Println(err)
-		return err
-	}
 	klog.Errorf("[writeDOMevents] sending patch op to client:%v,  %+v\n", ws.conn.RemoteAddr().String(), string(eventsData))
 	err = ws.conn.WriteMessage(websocket.TextMessage, eventsData)
 	if err != nil {
-		klog.Errorf("[writeDOMevents] error: writing message for channel:%v, closing conn with err %v", channel, err)
+		klog.Errorf("[writeDOMevents] error: writing message, closing conn with err %v", err)
 		ws.conn.Close()
 	}
 	return err
 }
 
-func writeEvent(ws *websocketConn, pubsubEvent pubsub.Event) error {
-	ws.Lock()
-	defer ws.Unlock()
-	reload := dom.Event{
-		Type: pubsubEvent.ID,
-	}
-	reloadData, err := json.Marshal([]dom.Event{reload})
+// routeFromSessionID decodes the page session id sent by the client and returns the route which rendered the page.
+func (c *controller) routeFromSessionID(sessionID string) (*route, error) {
+	ps, err := c.decodePageSession(sessionID)
 	if err != nil {
-		klog.Errorf("[writeReloadEvent] error: marshaling reload event %+v, err %v", reload, err)
-		return err
+		return nil, fmt.Errorf("invalid session id: %w", err)
 	}
-	err = ws.conn.WriteMessage(websocket.TextMessage, reloadData)
-	if err != nil {
-		klog.Errorf("[writeReloadEvent] error: writing message for channel:%v, closing conn with err %v", devReloadChannel, err)
-		ws.conn.Close()
+	c.RLock()
+	defer c.RUnlock()
+	rt, ok := c.routes[ps.RouteID]
+	if !ok {
+		return nil, fmt.Errorf("invalid session id: route %s not found", ps.RouteID)
 	}
-	return err
+	return rt, nil
 }
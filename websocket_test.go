@@ -0,0 +1,130 @@
+package fir
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/livefir/fir/internal/eventstate"
+	"github.com/livefir/fir/pubsub"
+	"github.com/stretchr/testify/assert"
+)
+
+var sessionIDMeta = regexp.MustCompile(`<meta name="fir-session-id" content="([^"]+)"`)
+
+func getPageSessionID(t *testing.T, url string) string {
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	match := sessionIDMeta.FindSubmatch(body)
+	if !assert.Len(t, match, 2) {
+		t.FailNow()
+	}
+	return string(match[1])
+}
+
+func TestWebsocketSessionID(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/", func() RouteOptions {
+		return RouteOptions{
+			ID("counter"),
+			Content(`<div @fir:inc:ok::count="">{{block "count" .}}<span>{{.count}}</span>{{end}}</div>`),
+			OnEvent("inc", func(ctx RouteContext) error { return ctx.KV("count", 1) }),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	sessionID := getPageSessionID(t, srv.URL)
+	assert.NotEqual(t, "counter", sessionID)
+	// every render gets its own page session
+	assert.NotEqual(t, sessionID, getPageSessionID(t, srv.URL))
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	for !c.(*controller).pubsub.HasSubscribers(context.Background(), "anonymous:counter") {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// tampered and raw route ids are rejected without closing the connection
+	for _, id := range []string{"counter", sessionID + "x"} {
+		err = conn.WriteJSON(map[string]any{"event_id": "inc", "session_id": id})
+		assert.NoError(t, err)
+	}
+	err = conn.WriteJSON(map[string]any{"event_id": "inc", "session_id": sessionID})
+	assert.NoError(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(message), "fir:inc:ok::count")
+}
+
+func TestWebsocketPageSessions(t *testing.T) {
+	c := NewController("test", WithPublicDir("."))
+	c.HandleFunc("/a", func() RouteOptions {
+		return RouteOptions{
+			ID("a"),
+			Content(`<div @fir:ping:ok::a="">{{block "a" .}}<span>{{.a}}</span>{{end}}</div>`),
+			OnEvent("ping", func(ctx RouteContext) error { return ctx.KV("a", "from-a") }),
+		}
+	})
+	c.HandleFunc("/b", func() RouteOptions {
+		return RouteOptions{
+			ID("b"),
+			Content(`<div @fir:ping:ok::b="">{{block "b" .}}<span>{{.b}}</span>{{end}}</div>`),
+			OnEvent("ping", func(ctx RouteContext) error { return ctx.KV("b", "from-b") }),
+		}
+	})
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	sessionA := getPageSessionID(t, srv.URL+"/a")
+	sessionB := getPageSessionID(t, srv.URL+"/b")
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/a", nil)
+	assert.NoError(t, err)
+	defer conn.Close()
+	for _, channel := range []string{"anonymous:a", "anonymous:b"} {
+		for !c.(*controller).pubsub.HasSubscribers(context.Background(), channel) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the same event id is dispatched to the route which rendered the page
+	err = conn.WriteJSON(map[string]any{"event_id": "ping", "session_id": sessionB})
+	assert.NoError(t, err)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(message), "from-b")
+	assert.NotContains(t, string(message), "from-a")
+
+	err = conn.WriteJSON(map[string]any{"event_id": "ping", "session_id": sessionA})
+	assert.NoError(t, err)
+	_, message, err = conn.ReadMessage()
+	assert.NoError(t, err)
+	assert.Contains(t, string(message), "from-a")
+}
+
+func TestConnSessionsAcceptsErrorsOfOwnPages(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?session_id=page1", nil)
+	sessions := newConnSessions(r, "")
+	page1, page2 := "page1", "page2"
+
+	assert.True(t, sessions.accepts(pubsub.Event{State: eventstate.OK, SessionID: &page2}))
+	assert.True(t, sessions.accepts(pubsub.Event{State: eventstate.Error, SessionID: &page1}))
+	assert.False(t, sessions.accepts(pubsub.Event{State: eventstate.Error, SessionID: &page2}))
+
+	sessions.add(page2)
+	assert.True(t, sessions.accepts(pubsub.Event{State: eventstate.Error, SessionID: &page2}))
+}